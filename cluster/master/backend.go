@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/QuarkChain/goquarkchain/account"
 	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/master/filters"
 	"github.com/QuarkChain/goquarkchain/cluster/rpc"
 	"github.com/QuarkChain/goquarkchain/cluster/service"
 	"github.com/QuarkChain/goquarkchain/consensus"
@@ -22,9 +23,7 @@ import (
 	"math/big"
 	"os"
 	"reflect"
-	"sort"
 	"sync"
-	"syscall"
 	"time"
 )
 
@@ -42,11 +41,14 @@ type QKCMasterBackend struct {
 	chainDb            ethdb.Database
 	shutdown           chan os.Signal
 	clusterConfig      *config.ClusterConfig
-	clientPool         map[string]*SlaveConnection
-	branchToSlaves     map[uint32][]*SlaveConnection
+	clientPool         map[string]SlaveConn
+	branchToSlaves     map[uint32][]SlaveConn
 	branchToShardStats map[uint32]*rpc.ShardStats
 	artificialTxConfig *rpc.ArtificialTxConfig
 	rootBlockChain     *core.RootBlockChain
+	filterEvents       *filters.EventSystem
+	miningMetrics      *StageMetrics
+	slaveHealths       map[string]*slaveHealth
 	logInfo            string
 }
 
@@ -56,9 +58,10 @@ func New(ctx *service.ServiceContext, cfg *config.ClusterConfig) (*QKCMasterBack
 		mstr = &QKCMasterBackend{
 			clusterConfig:      cfg,
 			eventMux:           ctx.EventMux,
-			clientPool:         make(map[string]*SlaveConnection),
-			branchToSlaves:     make(map[uint32][]*SlaveConnection, 0),
+			clientPool:         make(map[string]SlaveConn),
+			branchToSlaves:     make(map[uint32][]SlaveConn, 0),
 			branchToShardStats: make(map[uint32]*rpc.ShardStats),
+			slaveHealths:       make(map[string]*slaveHealth),
 			artificialTxConfig: &rpc.ArtificialTxConfig{
 				TargetRootBlockTime:  cfg.Quarkchain.Root.ConsensusConfig.TargetBlockTime,
 				TargetMinorBlockTime: cfg.Quarkchain.GetShardConfigByFullShardID(cfg.Quarkchain.GetGenesisShardIds()[0]).ConsensusConfig.TargetBlockTime,
@@ -89,6 +92,8 @@ func New(ctx *service.ServiceContext, cfg *config.ClusterConfig) (*QKCMasterBack
 	}
 	log.Info("qkc api backend", "slave client pool", len(mstr.clientPool))
 
+	mstr.filterEvents = filters.NewEventSystem(mstr.eventMux)
+
 	return mstr, nil
 }
 
@@ -129,7 +134,9 @@ func (s *QKCMasterBackend) Protocols() []p2p.Protocol {
 	return nil
 }
 
-// APIs return all apis for master Server
+// APIs return all apis for master Server. The GraphQL endpoint is served
+// over HTTP rather than JSON-RPC, so it isn't part of this list; see
+// GraphQLHandler, which the node mounts behind the --graphql flag.
 func (s *QKCMasterBackend) APIs() []ethRPC.API {
 	apis := qkcapi.GetAPIs(s)
 	return append(apis, []ethRPC.API{
@@ -139,6 +146,24 @@ func (s *QKCMasterBackend) APIs() []ethRPC.API {
 			Service:   NewServerSideOp(s),
 			Public:    false,
 		},
+		{
+			Namespace: "eth",
+			Version:   "3.0",
+			Service:   filters.NewPublicFilterAPI(s.filterEvents),
+			Public:    true,
+		},
+		{
+			Namespace: "engine",
+			Version:   "3.0",
+			Service:   NewEngineAPI(s),
+			Public:    false,
+		},
+		{
+			Namespace: "admin",
+			Version:   "3.0",
+			Service:   NewAdminAPI(s),
+			Public:    false,
+		},
 	}...)
 }
 
@@ -190,9 +215,30 @@ func (s *QKCMasterBackend) InitCluster() error {
 	if err := s.initShards(); err != nil {
 		return err
 	}
+	s.startSlaveEventFanIn()
 	return nil
 }
 
+// startSlaveEventFanIn consumes the streaming event RPC on every slave
+// connection and republishes whatever it pushes onto the master's own
+// event mux, so filters.EventSystem sees a single merged stream regardless
+// of which slave a minor block/tx/log originated on. Events are forwarded
+// as received, with no dedup by branch: when more than one slave in
+// branchToSlaves serves a branch, each of them posts its own copy.
+//
+// This only wires up the master side; see the doc comment on
+// SlaveConn.SubscribeEvents for the slave-side protocol work it depends on.
+func (s *QKCMasterBackend) startSlaveEventFanIn() {
+	for _, slaveConn := range s.clientPool {
+		slaveConn := slaveConn
+		go func() {
+			for ev := range slaveConn.SubscribeEvents() {
+				s.eventMux.Post(ev)
+			}
+		}()
+	}
+}
+
 func (s *QKCMasterBackend) ConnectToSlaves() error {
 	fullShardIds := s.clusterConfig.Quarkchain.GetGenesisShardIds()
 	for _, slaveConn := range s.clientPool {
@@ -203,23 +249,30 @@ func (s *QKCMasterBackend) ConnectToSlaves() error {
 		if err := checkPing(slaveConn, id, chainMaskList); err != nil {
 			return err
 		}
+		s.lock.Lock()
 		for _, fullShardID := range fullShardIds {
-			if slaveConn.hasShard(fullShardID) {
+			if slaveConn.HasShard(fullShardID) {
 				s.branchToSlaves[fullShardID] = append(s.branchToSlaves[fullShardID], slaveConn)
 			}
 		}
+		s.slaveHealths[slaveConn.Target()] = newSlaveHealth(slaveConn.Target())
+		s.lock.Unlock()
 	}
 	return nil
 }
 func (s *QKCMasterBackend) logSummary() {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	for branch, slaves := range s.branchToSlaves {
 		for _, slave := range slaves {
-			log.Info(s.logInfo, "branch:", branch, "is run by slave", slave.slaveID)
+			log.Info(s.logInfo, "branch:", branch, "is run by slave", slave.SlaveID())
 		}
 	}
 }
 
 func (s *QKCMasterBackend) hasAllShards() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	if len(s.branchToSlaves) == len(s.clusterConfig.Quarkchain.GetGenesisShardIds()) {
 		for _, v := range s.branchToSlaves {
 			if len(v) == 0 {
@@ -256,43 +309,30 @@ func (s *QKCMasterBackend) initShards() error {
 	return g.Wait()
 }
 
-func (s *QKCMasterBackend) Heartbeat() {
-	go func(normal bool) {
-		for normal {
-			timeGap := time.Now()
-			for endpoint := range s.clientPool {
-				normal = s.clientPool[endpoint].HeartBeat()
-				if !normal {
-					s.shutdown <- syscall.SIGTERM
-					break
-				}
-			}
-			duration := time.Now().Sub(timeGap)
-			log.Trace(s.logInfo, "heart beat duration", duration.String())
-			time.Sleep(heartbeatInterval)
-		}
-	}(true)
-	//TODO :add send master info
-}
+// Heartbeat moved to slave_health.go, where it drives a per-slave circuit
+// breaker instead of tearing down the whole master on one slave's failure.
 
-func checkPing(slaveConn *SlaveConnection, id []byte, chainMaskList []*types.ChainMask) error {
-	if slaveConn.slaveID != string(id) {
+func checkPing(slaveConn SlaveConn, id []byte, chainMaskList []*types.ChainMask) error {
+	if slaveConn.SlaveID() != string(id) {
 		return errors.New("slaveID is not match")
 	}
-	if len(chainMaskList) != len(slaveConn.shardMaskList) {
+	if len(chainMaskList) != len(slaveConn.ShardMaskList()) {
 		return errors.New("chainMaskList is not match")
 	}
 	lenChainMaskList := len(chainMaskList)
+	shardMaskList := slaveConn.ShardMaskList()
 
 	for index := 0; index < lenChainMaskList; index++ {
-		if chainMaskList[index].GetMask() != slaveConn.shardMaskList[index].GetMask() {
+		if chainMaskList[index].GetMask() != shardMaskList[index].GetMask() {
 			return errors.New("chainMaskList index is not match")
 		}
 	}
 	return nil
 }
 
-func (s *QKCMasterBackend) getOneSlaveConnection(branch account.Branch) *SlaveConnection {
+func (s *QKCMasterBackend) getOneSlaveConnection(branch account.Branch) SlaveConn {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	slaves := s.branchToSlaves[branch.Value]
 	if len(slaves) < 1 {
 		return nil
@@ -300,7 +340,9 @@ func (s *QKCMasterBackend) getOneSlaveConnection(branch account.Branch) *SlaveCo
 	return slaves[0]
 }
 
-func (s *QKCMasterBackend) getAllSlaveConnection(fullShardID uint32) []*SlaveConnection {
+func (s *QKCMasterBackend) getAllSlaveConnection(fullShardID uint32) []SlaveConn {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	slaves := s.branchToSlaves[fullShardID]
 	if len(slaves) < 1 {
 		return nil
@@ -308,14 +350,17 @@ func (s *QKCMasterBackend) getAllSlaveConnection(fullShardID uint32) []*SlaveCon
 	return slaves
 }
 
-func (s *QKCMasterBackend) createRootBlockToMine(address account.Address) (*types.RootBlock, error) {
-	var g errgroup.Group
-	rspList := make(map[string]*rpc.GetUnconfirmedHeadersResponse)
+// createRootBlockToMine moved to mining_pipeline.go, where it runs as a
+// staged, cancellable, metrics-recording pipeline.
 
+// GetAccountData get account Data for jsonRpc
+func (s *QKCMasterBackend) GetAccountData(address account.Address, height *uint64) (map[account.Branch]*rpc.AccountBranchData, error) {
+	var g errgroup.Group
+	rspList := make(map[string]*rpc.GetAccountDataResponse)
 	for target := range s.clientPool {
 		target := target
 		g.Go(func() error {
-			rsp, err := s.clientPool[target].GetUnconfirmedHeaders()
+			rsp, err := s.clientPool[target].GetAccountData(address, height)
 			rspList[target] = rsp
 			return err
 		})
@@ -324,72 +369,61 @@ func (s *QKCMasterBackend) createRootBlockToMine(address account.Address) (*type
 		return nil, err
 	}
 
-	fullShardIDToHeaderList := make(map[uint32][]*types.MinorBlockHeader, 0)
-	for _, resp := range rspList {
-		for _, headersInfo := range resp.HeadersInfoList {
-			if _, ok := fullShardIDToHeaderList[headersInfo.Branch.Value]; ok { // to avoid overlap
-				continue // skip it if has added
-			}
-			height := uint64(0)
-			for _, header := range headersInfo.HeaderList {
-				if height != 0 && height+1 != header.Number {
-					return nil, errors.New("headers must ordered by height")
-				}
-				height = header.Number
-
-				if !s.rootBlockChain.IsMinorBlockValidated(header.Hash()) {
-					break
-				}
-				fullShardIDToHeaderList[headersInfo.Branch.Value] = append(fullShardIDToHeaderList[headersInfo.Branch.Value], header)
-			}
+	branchToAccountBranchData := make(map[account.Branch]*rpc.AccountBranchData)
+	for _, rsp := range rspList {
+		for _, accountBranchData := range rsp.AccountBranchDataList {
+			branchToAccountBranchData[accountBranchData.Branch] = accountBranchData
 		}
 	}
-
-	headerList := make([]*types.MinorBlockHeader, 0)
-	currTipHeight := s.rootBlockChain.CurrentBlock().Number()
-	fullShardIdToCheck := s.clusterConfig.Quarkchain.GetInitializedShardIdsBeforeRootHeight(currTipHeight + 1)
-	sort.Slice(fullShardIdToCheck, func(i, j int) bool { return fullShardIdToCheck[i] < fullShardIdToCheck[j] })
-	for _, fullShardID := range fullShardIdToCheck {
-		headers := fullShardIDToHeaderList[fullShardID]
-		headerList = append(headerList, headers...)
-	}
-	newblock, err := s.rootBlockChain.CreateBlockToMine(headerList, &address, nil)
-	if err != nil {
-		return nil, err
-	}
-	if err := s.rootBlockChain.Validator().ValidateBlock(newblock); err != nil {
-		//TODO :only for exposure problem ,need to delete later
-		panic(err)
+	if len(branchToAccountBranchData) != len(s.clusterConfig.Quarkchain.GetGenesisShardIds()) {
+		return nil, errors.New("len is not match")
 	}
-	return newblock, nil
+	return branchToAccountBranchData, nil
 }
 
-// GetAccountData get account Data for jsonRpc
-func (s *QKCMasterBackend) GetAccountData(address account.Address, height *uint64) (map[account.Branch]*rpc.AccountBranchData, error) {
+// GetAccountDataBatch is the batched form of GetAccountData: it issues one
+// GetAccountDataBatch RPC per slave covering every address in addresses, so
+// a caller resolving N addresses across M shards pays O(M) slave RPCs
+// instead of the O(N*M) that N separate GetAccountData calls would cost.
+func (s *QKCMasterBackend) GetAccountDataBatch(addresses []account.Address, height *uint64) (map[account.Address]map[account.Branch]*rpc.AccountBranchData, error) {
 	var g errgroup.Group
-	rspList := make(map[string]*rpc.GetAccountDataResponse)
+	var mu sync.Mutex
+	rspList := make(map[string]map[account.Address]*rpc.GetAccountDataResponse)
 	for target := range s.clientPool {
 		target := target
 		g.Go(func() error {
-			rsp, err := s.clientPool[target].GetAccountData(address, height)
+			rsp, err := s.clientPool[target].GetAccountDataBatch(addresses, height)
+			if err != nil {
+				return err
+			}
+			mu.Lock()
 			rspList[target] = rsp
-			return err
+			mu.Unlock()
+			return nil
 		})
 	}
 	if err := g.Wait(); err != nil {
 		return nil, err
 	}
 
-	branchToAccountBranchData := make(map[account.Branch]*rpc.AccountBranchData)
-	for _, rsp := range rspList {
-		for _, accountBranchData := range rsp.AccountBranchDataList {
-			branchToAccountBranchData[accountBranchData.Branch] = accountBranchData
+	out := make(map[account.Address]map[account.Branch]*rpc.AccountBranchData, len(addresses))
+	for _, address := range addresses {
+		branchToAccountBranchData := make(map[account.Branch]*rpc.AccountBranchData)
+		for _, perAddress := range rspList {
+			rsp, ok := perAddress[address]
+			if !ok {
+				continue
+			}
+			for _, accountBranchData := range rsp.AccountBranchDataList {
+				branchToAccountBranchData[accountBranchData.Branch] = accountBranchData
+			}
 		}
+		if len(branchToAccountBranchData) != len(s.clusterConfig.Quarkchain.GetGenesisShardIds()) {
+			return nil, errors.New("len is not match")
+		}
+		out[address] = branchToAccountBranchData
 	}
-	if len(branchToAccountBranchData) != len(s.clusterConfig.Quarkchain.GetGenesisShardIds()) {
-		return nil, errors.New("len is not match")
-	}
-	return branchToAccountBranchData, nil
+	return out, nil
 }
 
 // GetPrimaryAccountData get primary account data for jsonRpc
@@ -441,6 +475,7 @@ func (s *QKCMasterBackend) AddRootBlock(rootBlock *types.RootBlock) error {
 		return err
 	}
 	s.rootBlockChain.ClearCommittingHash()
+	s.eventMux.Post(filters.RootHeadEvent{Block: rootBlock})
 	return nil
 }
 
@@ -0,0 +1,240 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	ErrUnknownPayload     = errors.New("unknown payload")
+	ErrInvalidPayloadSeal = errors.New("sealed block does not match cached payload template")
+)
+
+const (
+	// payloadTTL bounds how long an unredeemed payload is kept around; an
+	// external miner that never calls back in with NewPayload/NewShardPayload
+	// shouldn't be able to pin memory forever.
+	payloadTTL = 2 * time.Minute
+	// maxCachedPayloads caps each cache independently of the TTL, so a caller
+	// that mints payloads faster than they expire still can't grow the maps
+	// without bound.
+	maxCachedPayloads = 128
+)
+
+// PayloadID identifies a block template built by GetRootPayload/GetShardPayload
+// and later redeemed by GetPayload/GetShardBlockPayload, the same way engine_forkchoiceUpdated's
+// payloadId threads through to engine_getPayload in the eth2 engine API.
+type PayloadID uint64
+
+// rootPayload is the cached template for an in-flight root block build: the
+// exact header list CreateBlockToMine assembled, kept so a later getPayload
+// call returns precisely the bytes the miner is expected to seal.
+type rootPayload struct {
+	block      *types.RootBlock
+	headerList []*types.MinorBlockHeader
+	createdAt  time.Time
+}
+
+// shardPayload is the per-shard equivalent, proxied to whichever slave owns
+// the branch.
+type shardPayload struct {
+	fullShardID uint32
+	block       *types.MinorBlock
+	createdAt   time.Time
+}
+
+// EngineAPI exposes an Engine-API-style interface so mining/consensus can
+// run as an external process instead of being embedded in the master: it
+// lets a caller request a fresh block template (engine_getPayload-style),
+// and submit a sealed block (engine_newPayload-style), for either the root
+// chain or an individual shard.
+type EngineAPI struct {
+	master *QKCMasterBackend
+
+	mu           sync.Mutex
+	nextID       PayloadID
+	rootPayloads map[PayloadID]*rootPayload
+	shardPayload map[PayloadID]*shardPayload
+}
+
+// NewEngineAPI returns an EngineAPI backed by the given master.
+func NewEngineAPI(master *QKCMasterBackend) *EngineAPI {
+	return &EngineAPI{
+		master:       master,
+		rootPayloads: make(map[PayloadID]*rootPayload),
+		shardPayload: make(map[PayloadID]*shardPayload),
+	}
+}
+
+func (api *EngineAPI) allocPayloadID() PayloadID {
+	api.nextID++
+	return api.nextID
+}
+
+// pruneRootPayloadsLocked drops expired root payloads and, if the cache is
+// still at capacity, evicts the oldest entries until it isn't. Callers must
+// hold api.mu.
+func (api *EngineAPI) pruneRootPayloadsLocked() {
+	now := time.Now()
+	for id, p := range api.rootPayloads {
+		if now.Sub(p.createdAt) > payloadTTL {
+			delete(api.rootPayloads, id)
+		}
+	}
+	for len(api.rootPayloads) >= maxCachedPayloads {
+		oldest, oldestAt := PayloadID(0), time.Time{}
+		for id, p := range api.rootPayloads {
+			if oldestAt.IsZero() || p.createdAt.Before(oldestAt) {
+				oldest, oldestAt = id, p.createdAt
+			}
+		}
+		delete(api.rootPayloads, oldest)
+	}
+}
+
+// pruneShardPayloadsLocked is the shardPayload equivalent of
+// pruneRootPayloadsLocked. Callers must hold api.mu.
+func (api *EngineAPI) pruneShardPayloadsLocked() {
+	now := time.Now()
+	for id, p := range api.shardPayload {
+		if now.Sub(p.createdAt) > payloadTTL {
+			delete(api.shardPayload, id)
+		}
+	}
+	for len(api.shardPayload) >= maxCachedPayloads {
+		oldest, oldestAt := PayloadID(0), time.Time{}
+		for id, p := range api.shardPayload {
+			if oldestAt.IsZero() || p.createdAt.Before(oldestAt) {
+				oldest, oldestAt = id, p.createdAt
+			}
+		}
+		delete(api.shardPayload, oldest)
+	}
+}
+
+// GetRootPayload builds a new root block template on top of the current
+// root tip for the given coinbase and returns an opaque id a later
+// GetPayload call can redeem. It runs the staged pipeline via
+// createRootBlockToMineCtx, bound to the RPC caller's ctx, so a caller that
+// times out or disconnects actually aborts in-flight stages instead of the
+// request hardcoding context.Background() as createRootBlockToMine does.
+func (api *EngineAPI) GetRootPayload(ctx context.Context, coinbase account.Address) (PayloadID, error) {
+	block, err := api.master.createRootBlockToMineCtx(ctx, coinbase)
+	if err != nil {
+		return 0, err
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.pruneRootPayloadsLocked()
+	id := api.allocPayloadID()
+	api.rootPayloads[id] = &rootPayload{
+		block:      block,
+		headerList: block.MinorBlockHeaders(),
+		createdAt:  time.Now(),
+	}
+	return id, nil
+}
+
+// GetPayload returns the root block template previously cached under id.
+func (api *EngineAPI) GetPayload(id PayloadID) (*types.RootBlock, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	p, ok := api.rootPayloads[id]
+	if !ok {
+		return nil, ErrUnknownPayload
+	}
+	return p.block, nil
+}
+
+// NewPayload submits a sealed root block: the seal is checked against the
+// cached template (same id, same header list) before the block is inserted
+// into rootBlockChain and broadcast to every slave via AddRootBlock.
+func (api *EngineAPI) NewPayload(id PayloadID, sealed *types.RootBlock) error {
+	api.mu.Lock()
+	p, ok := api.rootPayloads[id]
+	if ok {
+		delete(api.rootPayloads, id)
+	}
+	api.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownPayload
+	}
+	if sealed.Header().HashWithoutNonce() != p.block.Header().HashWithoutNonce() {
+		return ErrInvalidPayloadSeal
+	}
+
+	log.Info(api.master.logInfo, "engine newPayload", "payloadId", id, "height", sealed.NumberU64())
+	return api.master.AddRootBlock(sealed)
+}
+
+// GetShardPayload builds a block template for fullShardID on the slave that
+// owns it and caches it under a fresh id, mirroring GetRootPayload but
+// proxied through the responsible SlaveConnection. It takes ctx for the
+// same RPC-caller-cancellation reasons as GetRootPayload, though the
+// underlying CreateBlockToMine slave RPC is not itself cancellable yet.
+func (api *EngineAPI) GetShardPayload(ctx context.Context, fullShardID uint32, coinbase account.Address) (PayloadID, error) {
+	slaveConn := api.master.getOneSlaveConnection(account.Branch{Value: fullShardID})
+	if slaveConn == nil {
+		return 0, ErrNoBranchConn
+	}
+
+	block, err := slaveConn.CreateBlockToMine(fullShardID, coinbase)
+	if err != nil {
+		return 0, err
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.pruneShardPayloadsLocked()
+	id := api.allocPayloadID()
+	api.shardPayload[id] = &shardPayload{fullShardID: fullShardID, block: block, createdAt: time.Now()}
+	return id, nil
+}
+
+// GetShardBlockPayload returns the cached minor block template for id.
+func (api *EngineAPI) GetShardBlockPayload(id PayloadID) (*types.MinorBlock, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	p, ok := api.shardPayload[id]
+	if !ok {
+		return nil, ErrUnknownPayload
+	}
+	return p.block, nil
+}
+
+// NewShardPayload submits a sealed minor block to the slave that owns its
+// branch, after checking it still matches the cached template.
+func (api *EngineAPI) NewShardPayload(id PayloadID, sealed *types.MinorBlock) error {
+	api.mu.Lock()
+	p, ok := api.shardPayload[id]
+	if ok {
+		delete(api.shardPayload, id)
+	}
+	api.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownPayload
+	}
+	if sealed.Header().HashWithoutNonce() != p.block.Header().HashWithoutNonce() {
+		return ErrInvalidPayloadSeal
+	}
+
+	slaveConn := api.master.getOneSlaveConnection(account.Branch{Value: p.fullShardID})
+	if slaveConn == nil {
+		return ErrNoBranchConn
+	}
+	return slaveConn.AddBlock(sealed)
+}
+
+func (id PayloadID) String() string {
+	return fmt.Sprintf("0x%x", uint64(id))
+}
@@ -0,0 +1,93 @@
+package filters
+
+import (
+	"context"
+	"errors"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	ethRPC "github.com/ethereum/go-ethereum/rpc"
+)
+
+var errUnsupportedSubscriptionType = errors.New("unsupported subscription type")
+
+// errNoSlaveEventSource is returned by the subscription endpoints that can
+// only ever fire once slaves push MinorHeadEvent/NewLogsEvent/
+// RemovedLogsEvent/NewPendingTransactionEvent onto the master's event mux.
+// That requires a streaming RPC on the slave wire protocol
+// (cluster/rpc, see the doc comment on master.SlaveConn.SubscribeEvents)
+// which does not exist yet, so these endpoints fail fast instead of
+// silently installing a subscription that can never deliver anything.
+var errNoSlaveEventSource = errors.New("filters: not yet supported, requires the slave event streaming RPC (see SlaveConn.SubscribeEvents)")
+
+// PublicFilterAPI exposes eth_subscribe-style subscriptions backed by the
+// master's EventSystem. It is registered under the "eth" namespace so
+// existing eth_subscribe clients work unmodified against a QKCMasterBackend.
+//
+// Only NewRootHeads is backed by a real event source today: RootHeadEvent is
+// posted from QKCMasterBackend.AddRootBlock directly. NewHeads,
+// NewPendingTransactions, and Logs all depend on slave-sourced events that
+// nothing in this tree posts yet (see errNoSlaveEventSource), so they
+// return that error immediately rather than installing a subscription that
+// would silently never fire.
+type PublicFilterAPI struct {
+	events *EventSystem
+}
+
+// NewPublicFilterAPI returns a filter API wired to the given event system.
+func NewPublicFilterAPI(es *EventSystem) *PublicFilterAPI {
+	return &PublicFilterAPI{events: es}
+}
+
+// NewHeads would send a notification each time a new minor block header is
+// appended to the chain, optionally restricted to a single branch; see the
+// PublicFilterAPI doc comment for why it returns errNoSlaveEventSource
+// instead.
+func (api *PublicFilterAPI) NewHeads(ctx context.Context, branch *account.Branch) (*ethRPC.Subscription, error) {
+	return nil, errNoSlaveEventSource
+}
+
+// NewRootHeads sends a notification each time the master's root block
+// chain head advances.
+func (api *PublicFilterAPI) NewRootHeads(ctx context.Context) (*ethRPC.Subscription, error) {
+	notifier, supported := ethRPC.NotifierFromContext(ctx)
+	if !supported {
+		return &ethRPC.Subscription{}, ethRPC.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+	go func() {
+		blocks := make(chan *types.RootBlock)
+		rootSub := api.events.SubscribeNewRootHeads(blocks)
+
+		for {
+			select {
+			case b := <-blocks:
+				notifier.Notify(rpcSub.ID, b.Header())
+			case <-rpcSub.Err():
+				rootSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				rootSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
+// NewPendingTransactions would send a notification each time a transaction
+// enters a shard's pending pool; see the PublicFilterAPI doc comment for
+// why it returns errNoSlaveEventSource instead.
+func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*ethRPC.Subscription, error) {
+	return nil, errNoSlaveEventSource
+}
+
+// Logs would create a subscription that fires for every log entry matching
+// the given filter criteria, including removed logs emitted by a reorg; see
+// the PublicFilterAPI doc comment for why it returns errNoSlaveEventSource
+// instead.
+func (api *PublicFilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*ethRPC.Subscription, error) {
+	return nil, errNoSlaveEventSource
+}
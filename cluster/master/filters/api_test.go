@@ -0,0 +1,27 @@
+package filters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// TestUnsupportedSubscriptionsFailFast checks that the endpoints with no
+// real slave-sourced event feed return errNoSlaveEventSource immediately
+// instead of installing a subscription that would silently never deliver
+// anything.
+func TestUnsupportedSubscriptionsFailFast(t *testing.T) {
+	api := NewPublicFilterAPI(NewEventSystem(new(event.TypeMux)))
+	ctx := context.Background()
+
+	if _, err := api.NewHeads(ctx, nil); err != errNoSlaveEventSource {
+		t.Fatalf("NewHeads: expected errNoSlaveEventSource, got %v", err)
+	}
+	if _, err := api.NewPendingTransactions(ctx); err != errNoSlaveEventSource {
+		t.Fatalf("NewPendingTransactions: expected errNoSlaveEventSource, got %v", err)
+	}
+	if _, err := api.Logs(ctx, FilterCriteria{}); err != errNoSlaveEventSource {
+		t.Fatalf("Logs: expected errNoSlaveEventSource, got %v", err)
+	}
+}
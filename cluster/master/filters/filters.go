@@ -0,0 +1,347 @@
+// Package filters implements an ethereum filtering system for block,
+// transactions and log events, fanned in from every slave of a
+// QKCMasterBackend together with the master's own root block chain.
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// Type determines the kind of filter and is used to put the filter in to
+// the correct bucket when raising events.
+type Type byte
+
+const (
+	// UnknownSubscription indicates an unknown subscription type
+	UnknownSubscription Type = iota
+	// NewRootHeadsSubscription queries for new root chain headers
+	NewRootHeadsSubscription
+	// NewMinorHeadsSubscription queries for new minor chain headers, across
+	// every branch the local slaves are responsible for
+	NewMinorHeadsSubscription
+	// PendingTransactionsSubscription queries tx hashes for pending
+	// transactions entering the pending state
+	PendingTransactionsSubscription
+	// LogsSubscription queries for new or removed (reorg) logs
+	LogsSubscription
+)
+
+var (
+	// ErrInvalidSubscriptionID is returned when the filter API is asked to
+	// unsubscribe an id it has no record of
+	ErrInvalidSubscriptionID = errors.New("invalid id")
+)
+
+// FilterCriteria mirrors go-ethereum's log filter criteria, extended with
+// the QuarkChain branch so a single master can multiplex many shards.
+type FilterCriteria struct {
+	Branch    *account.Branch
+	FromBlock *uint64
+	ToBlock   *uint64
+	Addresses []common.Address
+	Topics    [][]common.Hash
+}
+
+// RootHeadEvent and MinorHeadEvent are posted on the master's event mux
+// whenever the corresponding chain head advances.
+type RootHeadEvent struct{ Block *types.RootBlock }
+type MinorHeadEvent struct {
+	Branch account.Branch
+	Header *types.MinorBlockHeader
+}
+
+// RemovedLogsEvent is posted when a reorg removes blocks (and therefore
+// their logs) from the canonical chain.
+type RemovedLogsEvent struct{ Logs []*types.Log }
+
+// NewLogsEvent is posted when logs are produced by newly added blocks.
+type NewLogsEvent struct{ Logs []*types.Log }
+
+// NewPendingTransactionEvent is posted for every transaction entering the
+// pending set of a shard.
+type NewPendingTransactionEvent struct{ Tx *types.Transaction }
+
+// subscription is the internal representation of a filter's event channel.
+type subscription struct {
+	id         string
+	typ        Type
+	branch     *account.Branch
+	created    time.Time
+	logsCrit   FilterCriteria
+	logs       chan []*types.Log
+	rootHeads  chan *types.RootBlock
+	minorHeads chan *MinorHeadEvent
+	txs        chan *types.Transaction
+	installed  chan struct{}
+	err        chan error
+}
+
+// EventSystem creates subscriptions, processes events fanned in from all
+// SlaveConnections (via the master's startSlaveEventFanIn) and the master's own
+// rootBlockChain, and routes matching events to the right subscriptions.
+//
+// It is modeled on go-ethereum's filters.EventSystem: a single goroutine
+// owns all subscription bookkeeping so installs/uninstalls never race with
+// delivery.
+type EventSystem struct {
+	mux *event.TypeMux
+
+	rootSub  *event.TypeMuxSubscription
+	minorSub *event.TypeMuxSubscription
+	logsSub  *event.TypeMuxSubscription
+	txsSub   *event.TypeMuxSubscription
+
+	install   chan *subscription
+	uninstall chan *subscription
+}
+
+// NewEventSystem creates a new EventSystem and starts its dispatch loop.
+// mux is the same event.TypeMux the QKCMasterBackend already owns; the
+// caller is expected to Post RootHeadEvent/MinorHeadEvent/NewLogsEvent/
+// RemovedLogsEvent/NewPendingTransactionEvent onto it as SlaveConnections
+// stream them in.
+func NewEventSystem(mux *event.TypeMux) *EventSystem {
+	es := &EventSystem{
+		mux:       mux,
+		install:   make(chan *subscription),
+		uninstall: make(chan *subscription),
+	}
+	es.rootSub = mux.Subscribe(RootHeadEvent{})
+	es.minorSub = mux.Subscribe(MinorHeadEvent{})
+	es.logsSub = mux.Subscribe(NewLogsEvent{}, RemovedLogsEvent{})
+	es.txsSub = mux.Subscribe(NewPendingTransactionEvent{})
+
+	go es.eventLoop()
+	return es
+}
+
+func (es *EventSystem) subscribe(sub *subscription) *Subscription {
+	es.install <- sub
+	<-sub.installed
+	return &Subscription{ID: sub.id, es: es, sub: sub}
+}
+
+// SubscribeNewRootHeads creates a subscription that writes new root block
+// headers to the given channel.
+func (es *EventSystem) SubscribeNewRootHeads(ch chan *types.RootBlock) *Subscription {
+	sub := &subscription{
+		id:        newSubID(),
+		typ:       NewRootHeadsSubscription,
+		rootHeads: ch,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeNewMinorHeads creates a subscription for minor block headers,
+// optionally restricted to a single branch.
+func (es *EventSystem) SubscribeNewMinorHeads(branch *account.Branch, ch chan *MinorHeadEvent) *Subscription {
+	sub := &subscription{
+		id:         newSubID(),
+		typ:        NewMinorHeadsSubscription,
+		branch:     branch,
+		minorHeads: ch,
+		installed:  make(chan struct{}),
+		err:        make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribeLogs creates a subscription matching the given criteria. Logs
+// carried by a RemovedLogsEvent are redelivered on the same channel; callers
+// tell them apart from new logs via Log.Removed.
+func (es *EventSystem) SubscribeLogs(crit FilterCriteria, ch chan []*types.Log) *Subscription {
+	sub := &subscription{
+		id:        newSubID(),
+		typ:       LogsSubscription,
+		logsCrit:  crit,
+		logs:      ch,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// SubscribePendingTxs creates a subscription for new pending transactions.
+func (es *EventSystem) SubscribePendingTxs(ch chan *types.Transaction) *Subscription {
+	sub := &subscription{
+		id:        newSubID(),
+		typ:       PendingTransactionsSubscription,
+		txs:       ch,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+// Subscription is returned to callers; Err() is closed once the
+// subscription has been torn down by Unsubscribe.
+type Subscription struct {
+	ID  string
+	es  *EventSystem
+	sub *subscription
+}
+
+// Err returns a channel that is closed when Unsubscribe removes the
+// subscription; no error value is ever sent on it.
+func (s *Subscription) Err() <-chan error {
+	return s.sub.err
+}
+
+// Unsubscribe removes the subscription from the event system.
+func (s *Subscription) Unsubscribe() {
+	s.es.uninstall <- s.sub
+	<-s.sub.err
+}
+
+func (es *EventSystem) eventLoop() {
+	defer func() {
+		es.rootSub.Unsubscribe()
+		es.minorSub.Unsubscribe()
+		es.logsSub.Unsubscribe()
+		es.txsSub.Unsubscribe()
+	}()
+
+	index := make(map[Type]map[string]*subscription)
+	for _, t := range []Type{NewRootHeadsSubscription, NewMinorHeadsSubscription, LogsSubscription, PendingTransactionsSubscription} {
+		index[t] = make(map[string]*subscription)
+	}
+
+	for {
+		select {
+		case ev, ok := <-es.rootSub.Chan():
+			if !ok {
+				return
+			}
+			if e, ok := ev.Data.(RootHeadEvent); ok {
+				for _, sub := range index[NewRootHeadsSubscription] {
+					sub.rootHeads <- e.Block
+				}
+			}
+		case ev, ok := <-es.minorSub.Chan():
+			if !ok {
+				return
+			}
+			if e, ok := ev.Data.(MinorHeadEvent); ok {
+				for _, sub := range index[NewMinorHeadsSubscription] {
+					if sub.branch == nil || sub.branch.Value == e.Branch.Value {
+						sub.minorHeads <- &e
+					}
+				}
+			}
+		case ev, ok := <-es.logsSub.Chan():
+			if !ok {
+				return
+			}
+			switch e := ev.Data.(type) {
+			case NewLogsEvent:
+				for _, sub := range index[LogsSubscription] {
+					if matched := filterLogs(e.Logs, sub.logsCrit); len(matched) > 0 {
+						sub.logs <- matched
+					}
+				}
+			case RemovedLogsEvent:
+				// Removed logs are delivered on the same channel; callers
+				// distinguish them via Log.Removed, set by the chain on reorg.
+				for _, sub := range index[LogsSubscription] {
+					if matched := filterLogs(e.Logs, sub.logsCrit); len(matched) > 0 {
+						sub.logs <- matched
+					}
+				}
+			}
+		case ev, ok := <-es.txsSub.Chan():
+			if !ok {
+				return
+			}
+			if e, ok := ev.Data.(NewPendingTransactionEvent); ok {
+				for _, sub := range index[PendingTransactionsSubscription] {
+					sub.txs <- e.Tx
+				}
+			}
+		case sub := <-es.install:
+			index[sub.typ][sub.id] = sub
+			close(sub.installed)
+		case sub := <-es.uninstall:
+			delete(index[sub.typ], sub.id)
+			close(sub.err)
+		}
+	}
+}
+
+// filterLogs returns the subset of logs matching crit. Empty Addresses or
+// empty Topics means "match everything" for that dimension. crit.Branch is
+// not consulted: logs are matched by block range, address and topics only.
+func filterLogs(logs []*types.Log, crit FilterCriteria) []*types.Log {
+	var out []*types.Log
+	for _, l := range logs {
+		if crit.FromBlock != nil && l.BlockNumber < *crit.FromBlock {
+			continue
+		}
+		if crit.ToBlock != nil && l.BlockNumber > *crit.ToBlock {
+			continue
+		}
+		if len(crit.Addresses) > 0 && !containsAddress(crit.Addresses, l.Address) {
+			continue
+		}
+		if !matchTopics(crit.Topics, l.Topics) {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+func containsAddress(addrs []common.Address, addr common.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTopics(crit [][]common.Hash, topics []common.Hash) bool {
+	if len(crit) > len(topics) {
+		return false
+	}
+	for i, sub := range crit {
+		if len(sub) == 0 {
+			continue // wildcard position
+		}
+		found := false
+		for _, want := range sub {
+			if want == topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	subIDCounter uint64
+	subIDLock    sync.Mutex
+)
+
+// newSubID returns a process-unique subscription id to key the internal
+// index; the RPC-facing subscription id is assigned by the ethRPC
+// subscription machinery in api.go.
+func newSubID() string {
+	subIDLock.Lock()
+	defer subIDLock.Unlock()
+	subIDCounter++
+	return fmt.Sprintf("sub_%d", subIDCounter)
+}
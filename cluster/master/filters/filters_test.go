@@ -0,0 +1,75 @@
+package filters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+func waitLogs(t *testing.T, ch chan []*types.Log) []*types.Log {
+	t.Helper()
+	select {
+	case logs := <-ch:
+		return logs
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for logs")
+		return nil
+	}
+}
+
+// TestLogsSubscriptionRemoved checks that logs produced by a block which is
+// later reverted by a reorg are redelivered to an installed log
+// subscription, matching go-ethereum's removed-log semantics.
+func TestLogsSubscriptionRemoved(t *testing.T) {
+	mux := new(event.TypeMux)
+	es := NewEventSystem(mux)
+
+	addr := common.HexToAddress("0x0000000000000000000000000000000000000001")
+
+	matched := make(chan []*types.Log)
+	sub := es.SubscribeLogs(FilterCriteria{}, matched)
+	defer sub.Unsubscribe()
+
+	added := []*types.Log{{Address: addr, BlockNumber: 10}}
+	if err := mux.Post(NewLogsEvent{Logs: added}); err != nil {
+		t.Fatal(err)
+	}
+	got := waitLogs(t, matched)
+	if len(got) != 1 || got[0].BlockNumber != 10 {
+		t.Fatalf("unexpected logs delivered: %+v", got)
+	}
+
+	removed := []*types.Log{{Address: addr, BlockNumber: 10, Removed: true}}
+	if err := mux.Post(RemovedLogsEvent{Logs: removed}); err != nil {
+		t.Fatal(err)
+	}
+	got = waitLogs(t, matched)
+	if len(got) != 1 || !got[0].Removed {
+		t.Fatalf("expected removed log redelivery, got %+v", got)
+	}
+}
+
+// TestLogsSubscriptionFiltersByAddress checks that a subscription with an
+// address filter does not receive logs from other addresses.
+func TestLogsSubscriptionFiltersByAddress(t *testing.T) {
+	mux := new(event.TypeMux)
+	es := NewEventSystem(mux)
+
+	wanted := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	other := common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+	matched := make(chan []*types.Log)
+	sub := es.SubscribeLogs(FilterCriteria{Addresses: []common.Address{wanted}}, matched)
+	defer sub.Unsubscribe()
+
+	go mux.Post(NewLogsEvent{Logs: []*types.Log{{Address: other, BlockNumber: 1}}})
+	go mux.Post(NewLogsEvent{Logs: []*types.Log{{Address: wanted, BlockNumber: 2}}})
+
+	got := waitLogs(t, matched)
+	if len(got) != 1 || got[0].BlockNumber != 2 {
+		t.Fatalf("filter by address failed, got %+v", got)
+	}
+}
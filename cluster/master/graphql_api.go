@@ -0,0 +1,64 @@
+package master
+
+import (
+	"net/http"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/master/filters"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/QuarkChain/goquarkchain/graphql"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ShardStats returns a snapshot of branchToShardStats, for the graphql
+// service and admin tooling.
+func (s *QKCMasterBackend) ShardStats() map[uint32]*rpc.ShardStats {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	out := make(map[uint32]*rpc.ShardStats, len(s.branchToShardStats))
+	for k, v := range s.branchToShardStats {
+		out[k] = v
+	}
+	return out
+}
+
+// GetMinorBlockByHash proxies to the slave owning branch.
+func (s *QKCMasterBackend) GetMinorBlockByHash(branch account.Branch, hash common.Hash) (*types.MinorBlock, error) {
+	slaveConn := s.getOneSlaveConnection(branch)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetMinorBlockByHash(hash, branch)
+}
+
+// GetTransactionByHash proxies to the slave owning branch.
+func (s *QKCMasterBackend) GetTransactionByHash(branch account.Branch, hash common.Hash) (*types.Transaction, error) {
+	slaveConn := s.getOneSlaveConnection(branch)
+	if slaveConn == nil {
+		return nil, ErrNoBranchConn
+	}
+	return slaveConn.GetTransactionByHash(hash, branch)
+}
+
+// EventSystem exposes the master's filters.EventSystem so the graphql
+// package's newRootHead subscription shares the same event feed as
+// eth_subscribe.
+func (s *QKCMasterBackend) EventSystem() *filters.EventSystem {
+	return s.filterEvents
+}
+
+// GraphQLHandler builds the GraphQL http.Handler for this master. It
+// returns ok=false when the cluster config has GraphQL disabled, so
+// callers wiring up HTTP listeners (behind the --graphql CLI flag) only
+// mount it when asked to.
+func (s *QKCMasterBackend) GraphQLHandler() (handler http.Handler, ok bool, err error) {
+	if !s.clusterConfig.EnableGraphQL {
+		return nil, false, nil
+	}
+	svc, err := graphql.New(s)
+	if err != nil {
+		return nil, false, err
+	}
+	return svc, true, nil
+}
@@ -0,0 +1,269 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrHeadersNotOrdered is returned by FilterValidatedHeadersStage when a
+// slave's unconfirmed header list is not ordered by height.
+var ErrHeadersNotOrdered = errors.New("headers must ordered by height")
+
+// Stage is one step of the miningPipeline that builds a root block
+// template, in the spirit of erigon's staged sync: each stage reads/writes
+// the shared pipeline state and can be run in isolation (from tests, or
+// from an admin RPC) for debugging.
+type Stage interface {
+	Name() string
+	Run(ctx context.Context, p *miningPipeline) error
+}
+
+// StageMetrics records per-stage run counts and durations. It is
+// intentionally a tiny, dependency-free stand-in for a Prometheus registry
+// so the pipeline stays observable without pulling in a metrics client.
+type StageMetrics struct {
+	mu    sync.Mutex
+	runs  map[string]int
+	total map[string]time.Duration
+	last  map[string]time.Duration
+}
+
+// NewStageMetrics returns an empty StageMetrics registry.
+func NewStageMetrics() *StageMetrics {
+	return &StageMetrics{
+		runs:  make(map[string]int),
+		total: make(map[string]time.Duration),
+		last:  make(map[string]time.Duration),
+	}
+}
+
+func (m *StageMetrics) record(stage string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs[stage]++
+	m.total[stage] += d
+	m.last[stage] = d
+}
+
+// Snapshot returns the last observed duration for each stage that has run
+// at least once.
+func (m *StageMetrics) Snapshot() map[string]time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]time.Duration, len(m.last))
+	for k, v := range m.last {
+		out[k] = v
+	}
+	return out
+}
+
+// miningPipeline carries the state threaded through FetchUnconfirmedHeadersStage
+// -> FilterValidatedHeadersStage -> OrderByShardStage -> AssembleStage ->
+// ValidateStage as they build a root block template.
+type miningPipeline struct {
+	master  *QKCMasterBackend
+	address account.Address
+	metrics *StageMetrics
+
+	rspList                 map[string]*rpc.GetUnconfirmedHeadersResponse
+	fullShardIDToHeaderList map[uint32][]*types.MinorBlockHeader
+	headerList              []*types.MinorBlockHeader
+	result                  *types.RootBlock
+}
+
+func (s *QKCMasterBackend) newMiningPipeline(address account.Address) *miningPipeline {
+	s.lock.Lock()
+	if s.miningMetrics == nil {
+		s.miningMetrics = NewStageMetrics()
+	}
+	metrics := s.miningMetrics
+	s.lock.Unlock()
+	return &miningPipeline{master: s, address: address, metrics: metrics}
+}
+
+// runMiningStages runs the given stages in order, cancelling the whole
+// pipeline as soon as ctx is done or a stage returns an error.
+func runMiningStages(ctx context.Context, p *miningPipeline, stages ...Stage) error {
+	for _, stage := range stages {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		start := time.Now()
+		err := stage.Run(ctx, p)
+		p.metrics.record(stage.Name(), time.Since(start))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FetchUnconfirmedHeadersStage fans GetUnconfirmedHeaders out to every
+// slave concurrently; it is the cancellable replacement for the old
+// errgroup.Group fan-out that createRootBlockToMine used to run inline.
+type FetchUnconfirmedHeadersStage struct{}
+
+func (FetchUnconfirmedHeadersStage) Name() string { return "FetchUnconfirmedHeaders" }
+
+func (FetchUnconfirmedHeadersStage) Run(ctx context.Context, p *miningPipeline) error {
+	g, gCtx := errgroup.WithContext(ctx)
+	rspList := make(map[string]*rpc.GetUnconfirmedHeadersResponse)
+	var mu sync.Mutex
+
+	for target := range p.master.clientPool {
+		target := target
+		g.Go(func() error {
+			rsp, err := p.master.clientPool[target].GetUnconfirmedHeaders()
+			if err != nil {
+				return err
+			}
+			select {
+			case <-gCtx.Done():
+				return gCtx.Err()
+			default:
+			}
+			mu.Lock()
+			rspList[target] = rsp
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	p.rspList = rspList
+	return nil
+}
+
+// FilterValidatedHeadersStage dedupes headers by branch (a branch's
+// unconfirmed headers only need to come from one slave) and keeps only the
+// contiguous, already-validated prefix of each branch's header list.
+type FilterValidatedHeadersStage struct{}
+
+func (FilterValidatedHeadersStage) Name() string { return "FilterValidatedHeaders" }
+
+func (FilterValidatedHeadersStage) Run(ctx context.Context, p *miningPipeline) error {
+	fullShardIDToHeaderList := make(map[uint32][]*types.MinorBlockHeader)
+	for _, resp := range p.rspList {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		for _, headersInfo := range resp.HeadersInfoList {
+			if _, ok := fullShardIDToHeaderList[headersInfo.Branch.Value]; ok { // to avoid overlap
+				continue // skip it if has added
+			}
+			height := uint64(0)
+			for _, header := range headersInfo.HeaderList {
+				if height != 0 && height+1 != header.Number {
+					return ErrHeadersNotOrdered
+				}
+				height = header.Number
+
+				if !p.master.rootBlockChain.IsMinorBlockValidated(header.Hash()) {
+					break
+				}
+				fullShardIDToHeaderList[headersInfo.Branch.Value] = append(fullShardIDToHeaderList[headersInfo.Branch.Value], header)
+			}
+		}
+	}
+	p.fullShardIDToHeaderList = fullShardIDToHeaderList
+	return nil
+}
+
+// OrderByShardStage flattens the per-branch header map into the single
+// ordered list CreateBlockToMine expects: shards sorted by id, restricted
+// to the shards initialized as of the next root height.
+type OrderByShardStage struct{}
+
+func (OrderByShardStage) Name() string { return "OrderByShard" }
+
+func (OrderByShardStage) Run(ctx context.Context, p *miningPipeline) error {
+	headerList := make([]*types.MinorBlockHeader, 0)
+	currTipHeight := p.master.rootBlockChain.CurrentBlock().Number()
+	fullShardIdToCheck := p.master.clusterConfig.Quarkchain.GetInitializedShardIdsBeforeRootHeight(currTipHeight + 1)
+	sort.Slice(fullShardIdToCheck, func(i, j int) bool { return fullShardIdToCheck[i] < fullShardIdToCheck[j] })
+	for _, fullShardID := range fullShardIdToCheck {
+		headerList = append(headerList, p.fullShardIDToHeaderList[fullShardID]...)
+	}
+	p.headerList = headerList
+	return nil
+}
+
+// AssembleStage calls rootBlockChain.CreateBlockToMine with the ordered
+// header list to produce the candidate root block.
+type AssembleStage struct{}
+
+func (AssembleStage) Name() string { return "Assemble" }
+
+func (AssembleStage) Run(ctx context.Context, p *miningPipeline) error {
+	block, err := p.master.rootBlockChain.CreateBlockToMine(p.headerList, &p.address, nil)
+	if err != nil {
+		return err
+	}
+	p.result = block
+	return nil
+}
+
+// ValidateStage validates the assembled block before it is handed to the
+// caller, surfacing a structured error instead of panicking.
+type ValidateStage struct{}
+
+func (ValidateStage) Name() string { return "Validate" }
+
+func (ValidateStage) Run(ctx context.Context, p *miningPipeline) error {
+	if err := p.master.rootBlockChain.Validator().ValidateBlock(p.result); err != nil {
+		return err
+	}
+	return nil
+}
+
+// defaultMiningStages is the pipeline createRootBlockToMine runs in
+// production; tests and the admin RPC can run a subset directly against a
+// miningPipeline for debugging.
+var defaultMiningStages = []Stage{
+	FetchUnconfirmedHeadersStage{},
+	FilterValidatedHeadersStage{},
+	OrderByShardStage{},
+	AssembleStage{},
+	ValidateStage{},
+}
+
+// createRootBlockToMine runs the staged pipeline above to build a root
+// block template for address on top of the current root tip.
+func (s *QKCMasterBackend) createRootBlockToMine(address account.Address) (*types.RootBlock, error) {
+	return s.createRootBlockToMineCtx(context.Background(), address)
+}
+
+// CreateRootBlockToMine is the exported form of createRootBlockToMine, for
+// callers outside this package (the conformance harness's driver actions)
+// that need to trigger template generation without going through the
+// engine API's payload caching.
+func (s *QKCMasterBackend) CreateRootBlockToMine(address account.Address) (*types.RootBlock, error) {
+	return s.createRootBlockToMine(address)
+}
+
+// createRootBlockToMineCtx is the cancellable entry point; engine API
+// callers and tests that want to bound how long template generation may
+// take should use this instead.
+func (s *QKCMasterBackend) createRootBlockToMineCtx(ctx context.Context, address account.Address) (*types.RootBlock, error) {
+	p := s.newMiningPipeline(address)
+	if err := runMiningStages(ctx, p, defaultMiningStages...); err != nil {
+		log.Error(s.logInfo, "createRootBlockToMine failed", err)
+		return nil, err
+	}
+	return p.result, nil
+}
@@ -0,0 +1,162 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// stubSlaveConn is a SlaveConn that only GetUnconfirmedHeaders is wired up
+// on, for testing FetchUnconfirmedHeadersStage in isolation; every other
+// method is unused by that stage and panics if called.
+type stubSlaveConn struct {
+	rsp *rpc.GetUnconfirmedHeadersResponse
+	err error
+}
+
+func (s stubSlaveConn) Target() string                            { panic("not used by this stage") }
+func (s stubSlaveConn) SlaveID() string                           { panic("not used by this stage") }
+func (s stubSlaveConn) ShardMaskList() []*types.ChainMask          { panic("not used by this stage") }
+func (s stubSlaveConn) HasShard(fullShardID uint32) bool           { panic("not used by this stage") }
+func (s stubSlaveConn) SendPing(*types.RootBlock, bool) ([]byte, []*types.ChainMask, error) {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) HeartBeat() bool { panic("not used by this stage") }
+func (s stubSlaveConn) GetUnconfirmedHeaders() (*rpc.GetUnconfirmedHeadersResponse, error) {
+	return s.rsp, s.err
+}
+func (s stubSlaveConn) GetAccountData(account.Address, *uint64) (*rpc.GetAccountDataResponse, error) {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) GetAccountDataBatch([]account.Address, *uint64) (map[account.Address]*rpc.GetAccountDataResponse, error) {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) SendMiningConfigToSlaves(*rpc.ArtificialTxConfig, bool) error {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) AddRootBlock(*types.RootBlock, bool) error { panic("not used by this stage") }
+func (s stubSlaveConn) GenTx(uint32, uint32, *types.Transaction) error {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) CreateBlockToMine(uint32, account.Address) (*types.MinorBlock, error) {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) AddBlock(*types.MinorBlock) error { panic("not used by this stage") }
+func (s stubSlaveConn) GetMinorBlockByHash(common.Hash, account.Branch) (*types.MinorBlock, error) {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) GetTransactionByHash(common.Hash, account.Branch) (*types.Transaction, error) {
+	panic("not used by this stage")
+}
+func (s stubSlaveConn) SubscribeEvents() <-chan interface{} { panic("not used by this stage") }
+
+type fakeStage struct {
+	name string
+	ran  *[]string
+	err  error
+}
+
+func (f fakeStage) Name() string { return f.name }
+
+func (f fakeStage) Run(ctx context.Context, p *miningPipeline) error {
+	*f.ran = append(*f.ran, f.name)
+	return f.err
+}
+
+func TestRunMiningStagesStopsOnError(t *testing.T) {
+	var ran []string
+	wantErr := errors.New("boom")
+	stages := []Stage{
+		fakeStage{name: "a", ran: &ran},
+		fakeStage{name: "b", ran: &ran, err: wantErr},
+		fakeStage{name: "c", ran: &ran},
+	}
+
+	p := &miningPipeline{metrics: NewStageMetrics()}
+	err := runMiningStages(context.Background(), p, stages...)
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+	if got := ran; len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Fatalf("expected stages a,b to run and c to be skipped, got %v", got)
+	}
+}
+
+func TestRunMiningStagesRespectsCancellation(t *testing.T) {
+	var ran []string
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stages := []Stage{fakeStage{name: "a", ran: &ran}}
+	p := &miningPipeline{metrics: NewStageMetrics()}
+	err := runMiningStages(ctx, p, stages...)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(ran) != 0 {
+		t.Fatalf("expected no stages to run once cancelled, got %v", ran)
+	}
+}
+
+func TestStageMetricsRecordsLastDuration(t *testing.T) {
+	var ran []string
+	stages := []Stage{fakeStage{name: "a", ran: &ran}}
+	p := &miningPipeline{metrics: NewStageMetrics()}
+	if err := runMiningStages(context.Background(), p, stages...); err != nil {
+		t.Fatal(err)
+	}
+	snap := p.metrics.Snapshot()
+	if _, ok := snap["a"]; !ok {
+		t.Fatalf("expected metrics for stage a, got %v", snap)
+	}
+}
+
+// TestFetchUnconfirmedHeadersStageCollectsAllSlaves exercises
+// FetchUnconfirmedHeadersStage in isolation, the way an admin RPC or a test
+// is meant to be able to per Stage's doc comment.
+func TestFetchUnconfirmedHeadersStageCollectsAllSlaves(t *testing.T) {
+	want := &rpc.GetUnconfirmedHeadersResponse{}
+	master := &QKCMasterBackend{
+		clientPool: map[string]SlaveConn{
+			"slave0": stubSlaveConn{rsp: want},
+			"slave1": stubSlaveConn{rsp: want},
+		},
+	}
+	p := &miningPipeline{master: master, metrics: NewStageMetrics()}
+
+	if err := (FetchUnconfirmedHeadersStage{}).Run(context.Background(), p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(p.rspList) != 2 {
+		t.Fatalf("expected a response from both slaves, got %v", p.rspList)
+	}
+	for target, rsp := range p.rspList {
+		if rsp != want {
+			t.Fatalf("slave %s: expected the stubbed response, got %v", target, rsp)
+		}
+	}
+}
+
+// TestFetchUnconfirmedHeadersStagePropagatesSlaveError ensures a single
+// failing slave fails the whole stage rather than silently dropping its
+// headers.
+func TestFetchUnconfirmedHeadersStagePropagatesSlaveError(t *testing.T) {
+	wantErr := errors.New("slave unreachable")
+	master := &QKCMasterBackend{
+		clientPool: map[string]SlaveConn{
+			"slave0": stubSlaveConn{rsp: &rpc.GetUnconfirmedHeadersResponse{}},
+			"slave1": stubSlaveConn{err: wantErr},
+		},
+	}
+	p := &miningPipeline{master: master, metrics: NewStageMetrics()}
+
+	err := (FetchUnconfirmedHeadersStage{}).Run(context.Background(), p)
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+}
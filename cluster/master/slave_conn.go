@@ -0,0 +1,59 @@
+package master
+
+import (
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SlaveConn is the subset of *SlaveConnection the master needs, pulled out
+// as an interface so tests (and the conformance harness in particular) can
+// substitute a fake slave without dialing a real one. clientPool and
+// branchToSlaves are keyed/typed against this interface rather than the
+// concrete *SlaveConnection.
+type SlaveConn interface {
+	Target() string
+	SlaveID() string
+	ShardMaskList() []*types.ChainMask
+	HasShard(fullShardID uint32) bool
+
+	SendPing(minorBlock *types.RootBlock, initialize bool) ([]byte, []*types.ChainMask, error)
+	HeartBeat() bool
+
+	GetUnconfirmedHeaders() (*rpc.GetUnconfirmedHeadersResponse, error)
+	GetAccountData(address account.Address, height *uint64) (*rpc.GetAccountDataResponse, error)
+	// GetAccountDataBatch is GetAccountData for many addresses in one round
+	// trip to this slave, so QKCMasterBackend.GetAccountDataBatch can fan
+	// out once per slave regardless of how many addresses were requested.
+	GetAccountDataBatch(addresses []account.Address, height *uint64) (map[account.Address]*rpc.GetAccountDataResponse, error)
+	SendMiningConfigToSlaves(artificialTxConfig *rpc.ArtificialTxConfig, mining bool) error
+	AddRootBlock(rootBlock *types.RootBlock, force bool) error
+	GenTx(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error
+
+	CreateBlockToMine(fullShardID uint32, coinbase account.Address) (*types.MinorBlock, error)
+	AddBlock(block *types.MinorBlock) error
+	GetMinorBlockByHash(hash common.Hash, branch account.Branch) (*types.MinorBlock, error)
+	GetTransactionByHash(hash common.Hash, branch account.Branch) (*types.Transaction, error)
+
+	// SubscribeEvents streams filters events (MinorHeadEvent,
+	// NewPendingTransactionEvent, NewLogsEvent, RemovedLogsEvent) as they
+	// happen on this slave; see
+	// startSlaveEventFanIn.
+	//
+	// This depends on a streaming RPC method being added to the slave
+	// wire protocol (cluster/rpc) so slaves push events instead of the
+	// master polling for them. That protocol change is not part of this
+	// snapshot: cluster/rpc has no source here, only references, so
+	// SubscribeEvents cannot be backed by a real implementation yet. The
+	// master-side plumbing (startSlaveEventFanIn in backend.go, the interface
+	// method itself) is the half that can be written against this tree;
+	// *SlaveConnection.SubscribeEvents still needs to be implemented
+	// against the real slave protocol before this is load-bearing.
+	SubscribeEvents() <-chan interface{}
+}
+
+// compile-time assertion that the concrete client satisfies SlaveConn; kept
+// here rather than next to SlaveConnection's own definition since that
+// type lives outside this snapshot.
+var _ SlaveConn = (*SlaveConnection)(nil)
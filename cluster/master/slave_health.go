@@ -0,0 +1,329 @@
+package master
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SlaveState is the health state machine driven by heartbeat
+// successes/failures for a single SlaveConnection.
+type SlaveState int
+
+const (
+	// SlaveHealthy is the normal state: the last heartbeat succeeded.
+	SlaveHealthy SlaveState = iota
+	// SlaveDegraded means one or more recent heartbeats failed but the
+	// slave hasn't yet exhausted its retry budget.
+	SlaveDegraded
+	// SlaveReconnecting means the slave failed enough heartbeats that the
+	// connection is being re-dialed in the background. Slave selection via
+	// branchToSlaves does not consult this state.
+	SlaveReconnecting
+	// SlaveDead means reconnect attempts have been exhausted; the slave is
+	// treated as permanently gone until an operator intervenes.
+	SlaveDead
+)
+
+func (s SlaveState) String() string {
+	switch s {
+	case SlaveHealthy:
+		return "healthy"
+	case SlaveDegraded:
+		return "degraded"
+	case SlaveReconnecting:
+		return "reconnecting"
+	case SlaveDead:
+		return "dead"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	maxConsecutiveFailures = 3
+	maxReconnectBackoff    = 30 * time.Second
+	minReconnectBackoff    = time.Second
+)
+
+// slaveHealth tracks the state machine and backoff for one SlaveConnection.
+// All fields are guarded by the owning QKCMasterBackend's s.lock.
+type slaveHealth struct {
+	target      string
+	state       SlaveState
+	consecFails int
+	backoff     time.Duration
+	lastError   error
+	lastChecked time.Time
+}
+
+func newSlaveHealth(target string) *slaveHealth {
+	return &slaveHealth{target: target, state: SlaveHealthy, backoff: minReconnectBackoff}
+}
+
+// onSuccess transitions back to Healthy and resets the backoff.
+func (h *slaveHealth) onSuccess() {
+	h.state = SlaveHealthy
+	h.consecFails = 0
+	h.backoff = minReconnectBackoff
+	h.lastError = nil
+	h.lastChecked = time.Now()
+}
+
+// onFailure records a heartbeat failure and returns true once the slave
+// should move into SlaveReconnecting.
+func (h *slaveHealth) onFailure(err error) bool {
+	h.consecFails++
+	h.lastError = err
+	h.lastChecked = time.Now()
+	if h.consecFails < maxConsecutiveFailures {
+		h.state = SlaveDegraded
+		return false
+	}
+	h.state = SlaveReconnecting
+	return true
+}
+
+func (h *slaveHealth) nextBackoff() time.Duration {
+	d := h.backoff
+	h.backoff *= 2
+	if h.backoff > maxReconnectBackoff {
+		h.backoff = maxReconnectBackoff
+	}
+	return d
+}
+
+// SlaveStatus is the admin-facing view of a single slave's health.
+type SlaveStatus struct {
+	Target      string `json:"target"`
+	State       string `json:"state"`
+	ConsecFails int    `json:"consecFails"`
+	LastError   string `json:"lastError,omitempty"`
+}
+
+// ClusterStatus returns the health of every known slave connection, for the
+// admin_clusterStatus RPC.
+func (s *QKCMasterBackend) ClusterStatus() []SlaveStatus {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	out := make([]SlaveStatus, 0, len(s.slaveHealths))
+	for target, h := range s.slaveHealths {
+		status := SlaveStatus{Target: target, State: h.state.String(), ConsecFails: h.consecFails}
+		if h.lastError != nil {
+			status.LastError = h.lastError.Error()
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// AdminAPI exposes cluster operability endpoints that aren't part of the
+// public eth/engine surface.
+type AdminAPI struct {
+	master *QKCMasterBackend
+}
+
+// NewAdminAPI returns the admin_* RPC service for master.
+func NewAdminAPI(master *QKCMasterBackend) *AdminAPI {
+	return &AdminAPI{master: master}
+}
+
+// ClusterStatus reports every slave's heartbeat health so operators can
+// see which slaves are flapping without grepping logs.
+func (api *AdminAPI) ClusterStatus() []SlaveStatus {
+	return api.master.ClusterStatus()
+}
+
+// MiningPipelineMetrics reports the last observed duration of each stage in
+// the root-block mining pipeline, keyed by stage name, so an operator can
+// see which stage is slow without attaching a profiler. Empty until the
+// pipeline has run at least once.
+func (api *AdminAPI) MiningPipelineMetrics() map[string]time.Duration {
+	api.master.lock.RLock()
+	metrics := api.master.miningMetrics
+	api.master.lock.RUnlock()
+	if metrics == nil {
+		return map[string]time.Duration{}
+	}
+	return metrics.Snapshot()
+}
+
+// DebugCreateRootBlockToMine runs the mining pipeline once for coinbase and
+// returns the resulting root block, for exercising/debugging the
+// pipeline stages from an RPC call instead of only from tests.
+func (api *AdminAPI) DebugCreateRootBlockToMine(ctx context.Context, coinbase account.Address) (*types.RootBlock, error) {
+	return api.master.createRootBlockToMineCtx(ctx, coinbase)
+}
+
+// allSlavesDeadForBranch reports whether every slave serving branch is
+// currently Dead, in which case there is no point continuing to run the
+// master for that branch.
+func (s *QKCMasterBackend) allSlavesDeadForBranch(branch uint32) bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	slaves := s.branchToSlaves[branch]
+	if len(slaves) == 0 {
+		return false
+	}
+	for _, slave := range slaves {
+		h, ok := s.slaveHealths[slave.Target()]
+		if !ok || h.state != SlaveDead {
+			return false
+		}
+	}
+	return true
+}
+
+// heartbeatOnce runs one heartbeat round across every slave concurrently,
+// so a single slow or wedged slave cannot delay the rest. It updates each
+// slave's health state machine and returns the set of targets that just
+// transitioned into SlaveReconnecting.
+func (s *QKCMasterBackend) heartbeatOnce() []string {
+	s.lock.RLock()
+	targets := make([]string, 0, len(s.clientPool))
+	conns := make(map[string]SlaveConn, len(s.clientPool))
+	for target, conn := range s.clientPool {
+		targets = append(targets, target)
+		conns[target] = conn
+	}
+	s.lock.RUnlock()
+
+	var wg sync.WaitGroup
+	results := make(map[string]bool, len(targets))
+	var mu sync.Mutex
+	for _, target := range targets {
+		target := target
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok := conns[target].HeartBeat()
+			mu.Lock()
+			results[target] = ok
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var toReconnect []string
+	s.lock.Lock()
+	for target, ok := range results {
+		h, exists := s.slaveHealths[target]
+		if !exists {
+			h = newSlaveHealth(target)
+			s.slaveHealths[target] = h
+		}
+		// Reconnecting slaves are driven by reconnectSlave and Dead ones stay
+		// Dead, so a heartbeat result does not move either of them.
+		if h.state == SlaveReconnecting || h.state == SlaveDead {
+			continue
+		}
+		if ok {
+			h.onSuccess()
+			continue
+		}
+		if h.onFailure(errHeartbeatFailed) {
+			toReconnect = append(toReconnect, target)
+		}
+	}
+	s.lock.Unlock()
+
+	return toReconnect
+}
+
+var errHeartbeatFailed = errors.New("heartbeat failed")
+
+// Heartbeat starts the heartbeat and reconnect loops. Unlike the previous
+// implementation, a failing slave no longer brings down the whole master:
+// only once every slave serving a branch is Dead do we escalate to
+// shutdown, and reconnects are retried in the background with exponential
+// backoff instead of requiring a full restart.
+func (s *QKCMasterBackend) Heartbeat() {
+	go func() {
+		for {
+			toReconnect := s.heartbeatOnce()
+			for _, target := range toReconnect {
+				go s.reconnectSlave(target)
+			}
+
+			s.lock.RLock()
+			branches := make([]uint32, 0, len(s.branchToSlaves))
+			for branch := range s.branchToSlaves {
+				branches = append(branches, branch)
+			}
+			s.lock.RUnlock()
+
+			for _, branch := range branches {
+				if s.allSlavesDeadForBranch(branch) {
+					log.Error(s.logInfo, "all slaves dead for branch, shutting down", branch)
+					s.shutdown <- syscall.SIGTERM
+					return
+				}
+			}
+
+			time.Sleep(heartbeatInterval)
+		}
+	}()
+}
+
+// reconnectSlave repeatedly redials a Reconnecting slave with exponential
+// backoff, re-running SendPing/checkPing/initShards for that slave alone
+// once the dial succeeds.
+func (s *QKCMasterBackend) reconnectSlave(target string) {
+	s.lock.RLock()
+	conn, ok := s.clientPool[target]
+	s.lock.RUnlock()
+	if !ok {
+		return
+	}
+
+	for {
+		s.lock.RLock()
+		h := s.slaveHealths[target]
+		s.lock.RUnlock()
+		if h == nil || h.state != SlaveReconnecting {
+			return
+		}
+
+		s.lock.Lock()
+		backoff := h.nextBackoff()
+		s.lock.Unlock()
+		time.Sleep(backoff)
+
+		id, chainMaskList, err := conn.SendPing(nil, false)
+		if err == nil {
+			err = checkPing(conn, id, chainMaskList)
+		}
+		if err == nil {
+			currRootBlock := s.rootBlockChain.CurrentBlock()
+			if _, _, pingErr := conn.SendPing(currRootBlock, true); pingErr != nil {
+				err = pingErr
+			}
+		}
+
+		s.lock.Lock()
+		if err != nil {
+			h.consecFails++
+			h.lastError = err
+			if h.consecFails >= maxConsecutiveFailures*2 {
+				h.state = SlaveDead
+				s.lock.Unlock()
+				log.Error(s.logInfo, "slave reconnect exhausted, marking dead", target)
+				return
+			}
+			s.lock.Unlock()
+			continue
+		}
+		h.onSuccess()
+		s.lock.Unlock()
+		log.Info(s.logInfo, "slave reconnected", target)
+		return
+	}
+}
@@ -0,0 +1,68 @@
+package master
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSlaveHealthOnFailureStaysDegradedUntilBudgetExhausted(t *testing.T) {
+	h := newSlaveHealth("slave0")
+	failErr := errors.New("heartbeat failed")
+
+	for i := 1; i < maxConsecutiveFailures; i++ {
+		tripped := h.onFailure(failErr)
+		if tripped {
+			t.Fatalf("failure %d: expected no trip yet, breaker tripped early", i)
+		}
+		if h.state != SlaveDegraded {
+			t.Fatalf("failure %d: expected SlaveDegraded, got %v", i, h.state)
+		}
+	}
+
+	if tripped := h.onFailure(failErr); !tripped {
+		t.Fatalf("failure %d: expected breaker to trip", maxConsecutiveFailures)
+	}
+	if h.state != SlaveReconnecting {
+		t.Fatalf("expected SlaveReconnecting after %d consecutive failures, got %v", maxConsecutiveFailures, h.state)
+	}
+	if h.consecFails != maxConsecutiveFailures {
+		t.Fatalf("expected consecFails=%d, got %d", maxConsecutiveFailures, h.consecFails)
+	}
+}
+
+func TestSlaveHealthOnSuccessResetsState(t *testing.T) {
+	h := newSlaveHealth("slave0")
+	h.onFailure(errors.New("boom"))
+	h.onFailure(errors.New("boom"))
+
+	h.onSuccess()
+
+	if h.state != SlaveHealthy {
+		t.Fatalf("expected SlaveHealthy after onSuccess, got %v", h.state)
+	}
+	if h.consecFails != 0 {
+		t.Fatalf("expected consecFails reset to 0, got %d", h.consecFails)
+	}
+	if h.lastError != nil {
+		t.Fatalf("expected lastError cleared, got %v", h.lastError)
+	}
+	if h.backoff != minReconnectBackoff {
+		t.Fatalf("expected backoff reset to %v, got %v", minReconnectBackoff, h.backoff)
+	}
+}
+
+func TestSlaveHealthNextBackoffDoublesAndCaps(t *testing.T) {
+	h := newSlaveHealth("slave0")
+
+	first := h.nextBackoff()
+	if first != minReconnectBackoff {
+		t.Fatalf("expected first backoff %v, got %v", minReconnectBackoff, first)
+	}
+
+	for i := 0; i < 10; i++ {
+		h.nextBackoff()
+	}
+	if h.backoff != maxReconnectBackoff {
+		t.Fatalf("expected backoff capped at %v, got %v", maxReconnectBackoff, h.backoff)
+	}
+}
@@ -0,0 +1,54 @@
+package master
+
+import (
+	"errors"
+
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/master/filters"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// NewForConformance builds a QKCMasterBackend against an in-memory chain
+// db with the given slave connections wired in directly, skipping the
+// network dial New does. It exists for the conformance package (and any
+// other driver that wants deterministic, scripted slaves) to exercise the
+// same master code paths production traffic hits.
+func NewForConformance(cfg *config.ClusterConfig, slaves map[string]SlaveConn) (*QKCMasterBackend, error) {
+	mstr := &QKCMasterBackend{
+		clusterConfig:      cfg,
+		eventMux:           new(event.TypeMux),
+		clientPool:         make(map[string]SlaveConn, len(slaves)),
+		branchToSlaves:     make(map[uint32][]SlaveConn),
+		branchToShardStats: make(map[uint32]*rpc.ShardStats),
+		slaveHealths:       make(map[string]*slaveHealth),
+		logInfo:            "conformance",
+	}
+
+	chainDb := ethdb.NewMemDatabase()
+	engine, err := createConsensusEngine(nil, cfg.Quarkchain.Root)
+	if err != nil {
+		return nil, err
+	}
+	mstr.engine = engine
+
+	genesis := core.NewGenesis(cfg.Quarkchain)
+	genesis.MustCommitRootBlock(chainDb)
+	mstr.rootBlockChain, err = core.NewRootBlockChain(chainDb, nil, cfg.Quarkchain, mstr.engine, nil)
+	if err != nil {
+		return nil, err
+	}
+	mstr.chainDb = chainDb
+
+	for target, conn := range slaves {
+		mstr.clientPool[target] = conn
+	}
+	if err := mstr.ConnectToSlaves(); err != nil {
+		return nil, errors.New("conformance: ConnectToSlaves: " + err.Error())
+	}
+
+	mstr.filterEvents = filters.NewEventSystem(mstr.eventMux)
+	return mstr, nil
+}
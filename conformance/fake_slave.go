@@ -0,0 +1,110 @@
+package conformance
+
+import (
+	"errors"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/master"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var errUnscripted = errors.New("conformance: call not scripted for this vector")
+
+// fakeSlaveConn implements master.SlaveConn entirely from a SlaveScript, so
+// a vector's expected post-state only depends on what it declares, never on
+// a real network call.
+type fakeSlaveConn struct {
+	script   SlaveScript
+	shardSet map[uint32]bool
+}
+
+func newFakeSlaveConn(script SlaveScript) *fakeSlaveConn {
+	shardSet := make(map[uint32]bool, len(script.ShardIds))
+	for _, id := range script.ShardIds {
+		shardSet[id] = true
+	}
+	return &fakeSlaveConn{script: script, shardSet: shardSet}
+}
+
+var _ master.SlaveConn = (*fakeSlaveConn)(nil)
+
+func (f *fakeSlaveConn) Target() string  { return f.script.Target }
+func (f *fakeSlaveConn) SlaveID() string { return f.script.SlaveID }
+
+func (f *fakeSlaveConn) ShardMaskList() []*types.ChainMask {
+	masks := make([]*types.ChainMask, 0, len(f.script.ShardIds))
+	for _, id := range f.script.ShardIds {
+		masks = append(masks, types.NewChainMask(id))
+	}
+	return masks
+}
+
+func (f *fakeSlaveConn) HasShard(fullShardID uint32) bool { return f.shardSet[fullShardID] }
+
+func (f *fakeSlaveConn) SendPing(minorBlock *types.RootBlock, initialize bool) ([]byte, []*types.ChainMask, error) {
+	return []byte(f.script.SlaveID), f.ShardMaskList(), nil
+}
+
+func (f *fakeSlaveConn) HeartBeat() bool {
+	if f.script.Responses.HeartbeatOK != nil {
+		return *f.script.Responses.HeartbeatOK
+	}
+	return true
+}
+
+func (f *fakeSlaveConn) GetUnconfirmedHeaders() (*rpc.GetUnconfirmedHeadersResponse, error) {
+	if f.script.Responses.UnconfirmedHeaders == nil {
+		return nil, errUnscripted
+	}
+	return f.script.Responses.UnconfirmedHeaders, nil
+}
+
+func (f *fakeSlaveConn) GetAccountData(address account.Address, height *uint64) (*rpc.GetAccountDataResponse, error) {
+	if f.script.Responses.AccountData == nil {
+		return nil, errUnscripted
+	}
+	return f.script.Responses.AccountData, nil
+}
+
+func (f *fakeSlaveConn) GetAccountDataBatch(addresses []account.Address, height *uint64) (map[account.Address]*rpc.GetAccountDataResponse, error) {
+	if f.script.Responses.AccountData == nil {
+		return nil, errUnscripted
+	}
+	out := make(map[account.Address]*rpc.GetAccountDataResponse, len(addresses))
+	for _, addr := range addresses {
+		out[addr] = f.script.Responses.AccountData
+	}
+	return out, nil
+}
+
+func (f *fakeSlaveConn) SendMiningConfigToSlaves(cfg *rpc.ArtificialTxConfig, mining bool) error {
+	return nil
+}
+
+func (f *fakeSlaveConn) AddRootBlock(rootBlock *types.RootBlock, force bool) error { return nil }
+
+func (f *fakeSlaveConn) GenTx(numTxPerShard, xShardPercent uint32, tx *types.Transaction) error {
+	return nil
+}
+
+func (f *fakeSlaveConn) CreateBlockToMine(fullShardID uint32, coinbase account.Address) (*types.MinorBlock, error) {
+	return nil, errUnscripted
+}
+
+func (f *fakeSlaveConn) AddBlock(block *types.MinorBlock) error { return nil }
+
+func (f *fakeSlaveConn) GetMinorBlockByHash(hash common.Hash, branch account.Branch) (*types.MinorBlock, error) {
+	return nil, errUnscripted
+}
+
+func (f *fakeSlaveConn) GetTransactionByHash(hash common.Hash, branch account.Branch) (*types.Transaction, error) {
+	return nil, errUnscripted
+}
+
+func (f *fakeSlaveConn) SubscribeEvents() <-chan interface{} {
+	ch := make(chan interface{})
+	close(ch)
+	return ch
+}
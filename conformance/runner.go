@@ -0,0 +1,194 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/config"
+	"github.com/QuarkChain/goquarkchain/cluster/master"
+)
+
+// Report is the machine-readable result of replaying one Vector.
+type Report struct {
+	Name       string   `json:"name"`
+	Passed     bool     `json:"passed"`
+	Mismatches []string `json:"mismatches,omitempty"`
+	Err        string   `json:"err,omitempty"`
+}
+
+// LoadCorpus reads every *.json vector file from dir, sorted by filename so
+// runs are deterministic.
+func LoadCorpus(dir string) ([]Vector, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	vectors := make([]Vector, 0, len(paths))
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("conformance: reading %s: %v", path, err)
+		}
+		var v Vector
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("conformance: parsing %s: %v", path, err)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+// RunCorpus replays every vector in dir and returns one Report per vector,
+// in corpus order, for a CI job to turn into a pass/fail summary.
+func RunCorpus(dir string) ([]Report, error) {
+	vectors, err := LoadCorpus(dir)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]Report, 0, len(vectors))
+	for _, v := range vectors {
+		reports = append(reports, RunVector(v))
+	}
+	return reports, nil
+}
+
+// RunVector builds a fresh conformance backend from v.Cluster/v.Slaves, runs
+// v.Actions against it in order, and diffs the resulting state against
+// v.Expect.
+func RunVector(v Vector) Report {
+	report := Report{Name: v.Name}
+
+	cfg := newClusterConfig(v.Cluster)
+	slaves := make(map[string]master.SlaveConn, len(v.Slaves))
+	for _, script := range v.Slaves {
+		slaves[script.Target] = newFakeSlaveConn(script)
+	}
+
+	mstr, err := master.NewForConformance(cfg, slaves)
+	if err != nil {
+		report.Err = err.Error()
+		return report
+	}
+
+	var runErr error
+	for _, action := range v.Actions {
+		if runErr = runAction(mstr, action); runErr != nil {
+			break
+		}
+	}
+
+	if v.Expect.ExpectError != "" {
+		if runErr == nil || runErr.Error() != v.Expect.ExpectError {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf(
+				"expected error %q, got %v", v.Expect.ExpectError, runErr))
+		}
+	} else if runErr != nil {
+		report.Err = runErr.Error()
+		return report
+	}
+
+	if v.Expect.CurrentRootBlockHash != "" {
+		got := mstr.CurrentBlock().Hash().Hex()
+		if got != v.Expect.CurrentRootBlockHash {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf(
+				"currentRootBlockHash: expected %s, got %s", v.Expect.CurrentRootBlockHash, got))
+		}
+	}
+
+	if v.Expect.CurrentRootBlockNumber != nil {
+		got := mstr.CurrentBlock().Number()
+		if got != *v.Expect.CurrentRootBlockNumber {
+			report.Mismatches = append(report.Mismatches, fmt.Sprintf(
+				"currentRootBlockNumber: expected %d, got %d", *v.Expect.CurrentRootBlockNumber, got))
+		}
+	}
+
+	if len(v.Expect.ShardPendingTx) > 0 {
+		stats := mstr.ShardStats()
+		for branchKey, wantCount := range v.Expect.ShardPendingTx {
+			fullShardID, convErr := strconv.ParseUint(branchKey, 10, 32)
+			if convErr != nil {
+				report.Mismatches = append(report.Mismatches, fmt.Sprintf(
+					"shardPendingTx key %q is not a valid branch id", branchKey))
+				continue
+			}
+			stat, ok := stats[uint32(fullShardID)]
+			if !ok {
+				report.Mismatches = append(report.Mismatches, fmt.Sprintf(
+					"shardPendingTx: no stats for branch %s", branchKey))
+				continue
+			}
+			if stat.PendingTxCount != wantCount {
+				report.Mismatches = append(report.Mismatches, fmt.Sprintf(
+					"shardPendingTx[%s]: expected %d, got %d", branchKey, wantCount, stat.PendingTxCount))
+			}
+		}
+	}
+
+	report.Passed = report.Err == "" && len(report.Mismatches) == 0
+	return report
+}
+
+func newClusterConfig(spec ClusterSpec) *config.ClusterConfig {
+	cfg := config.NewClusterConfig()
+	cfg.Quarkchain.Root.Genesis.Difficulty = spec.GenesisRootDifficulty
+	if spec.FakeConsensus {
+		cfg.Quarkchain.Root.ConsensusType = config.PoWFake
+	}
+	for _, id := range spec.ShardIds {
+		cfg.Quarkchain.GetShardConfigByFullShardID(id)
+	}
+	return cfg
+}
+
+// mineAndInsertRootBlock builds a root block template for coinbase via the
+// mining pipeline and inserts it, the shared implementation behind
+// ActionAddRootBlock and ActionReorg; see the Action doc comment for why
+// neither action accepts a pre-built block over JSON.
+func mineAndInsertRootBlock(mstr *master.QKCMasterBackend, action Action) error {
+	var params struct {
+		Coinbase account.Address `json:"coinbase"`
+	}
+	if err := json.Unmarshal(action.Params, &params); err != nil {
+		return fmt.Errorf("conformance: %s params: %v", action.Kind, err)
+	}
+	block, err := mstr.CreateRootBlockToMine(params.Coinbase)
+	if err != nil {
+		return err
+	}
+	return mstr.AddRootBlock(block)
+}
+
+func runAction(mstr *master.QKCMasterBackend, action Action) error {
+	switch action.Kind {
+	case ActionAddRootBlock, ActionReorg:
+		return mineAndInsertRootBlock(mstr, action)
+
+	case ActionCreateRootBlockToMine:
+		var params struct {
+			Coinbase account.Address `json:"coinbase"`
+		}
+		if err := json.Unmarshal(action.Params, &params); err != nil {
+			return fmt.Errorf("conformance: CreateRootBlockToMine params: %v", err)
+		}
+		_, mineErr := mstr.CreateRootBlockToMine(params.Coinbase)
+		return mineErr
+
+	case ActionGetAccountData:
+		var params struct {
+			Address account.Address `json:"address"`
+		}
+		if err := json.Unmarshal(action.Params, &params); err != nil {
+			return fmt.Errorf("conformance: GetAccountData params: %v", err)
+		}
+		_, err := mstr.GetAccountData(params.Address, nil)
+		return err
+
+	default:
+		return fmt.Errorf("conformance: unknown action kind %q", action.Kind)
+	}
+}
@@ -0,0 +1,37 @@
+package conformance
+
+import "testing"
+
+func TestRunCorpusReplaysEveryVector(t *testing.T) {
+	reports, err := RunCorpus("testdata/vectors")
+	if err != nil {
+		t.Fatalf("RunCorpus: %v", err)
+	}
+	if len(reports) == 0 {
+		t.Fatal("expected at least one vector in the corpus")
+	}
+	for _, r := range reports {
+		if !r.Passed {
+			t.Errorf("vector %s failed: err=%q mismatches=%v", r.Name, r.Err, r.Mismatches)
+		}
+	}
+}
+
+func TestRunVectorReportsExpectedError(t *testing.T) {
+	v := Vector{
+		Name:    "unscripted_call_errors",
+		Cluster: ClusterSpec{ShardIds: []uint32{0}},
+		Slaves: []SlaveScript{
+			{Target: "slave0:38000", SlaveID: "S0", ShardIds: []uint32{0}},
+		},
+		Actions: []Action{
+			{Kind: ActionGetAccountData, Params: []byte(`{"address":{"recipient":"0x01","fullShardKey":0}}`)},
+		},
+		Expect: ExpectedState{ExpectError: errUnscripted.Error()},
+	}
+
+	report := RunVector(v)
+	if !report.Passed {
+		t.Errorf("expected vector to pass (error matched expectation), got err=%q mismatches=%v", report.Err, report.Mismatches)
+	}
+}
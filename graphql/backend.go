@@ -0,0 +1,29 @@
+// Package graphql exposes a read-mostly GraphQL view over a
+// QKCMasterBackend: accounts batched by branch, root/minor blocks and
+// transactions proxied through the owning slave, and cluster/shard stats,
+// with QuarkChain concepts (branch, fullShardKey, chainId, shardId) modeled
+// as first-class GraphQL types rather than opaque hex strings.
+package graphql
+
+import (
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/master/filters"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Backend is the subset of QKCMasterBackend the resolvers need. It is
+// defined here (rather than importing cluster/master directly) so the
+// graphql package has no dependency on the master's internal wiring and
+// can be unit tested against a fake.
+type Backend interface {
+	GetAccountData(address account.Address, height *uint64) (map[account.Branch]*rpc.AccountBranchData, error)
+	GetAccountDataBatch(addresses []account.Address, height *uint64) (map[account.Address]map[account.Branch]*rpc.AccountBranchData, error)
+	GetPrimaryAccountData(address account.Address, blockHeight *uint64) (*rpc.AccountBranchData, error)
+	CurrentBlock() *types.RootBlock
+	ShardStats() map[uint32]*rpc.ShardStats
+	GetMinorBlockByHash(branch account.Branch, hash common.Hash) (*types.MinorBlock, error)
+	GetTransactionByHash(branch account.Branch, hash common.Hash) (*types.Transaction, error)
+	EventSystem() *filters.EventSystem
+}
@@ -0,0 +1,48 @@
+package graphql
+
+import (
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// RootBlock resolves types.RootBlock.
+type RootBlock struct {
+	block *types.RootBlock
+}
+
+func (b *RootBlock) Header() *RootBlockHeader {
+	return &RootBlockHeader{header: b.block.Header()}
+}
+
+func (b *RootBlock) MinorHeaders() []*MinorBlockHeader {
+	headers := b.block.MinorBlockHeaders()
+	out := make([]*MinorBlockHeader, 0, len(headers))
+	for _, h := range headers {
+		out = append(out, &MinorBlockHeader{header: h})
+	}
+	return out
+}
+
+// RootBlockHeader resolves types.RootBlockHeader.
+type RootBlockHeader struct {
+	header *types.RootBlockHeader
+}
+
+func (h *RootBlockHeader) Hash() Bytes32 { return Bytes32(h.header.Hash()) }
+func (h *RootBlockHeader) Number() Long  { return Long(h.header.Number) }
+func (h *RootBlockHeader) ParentHash() Bytes32 {
+	return Bytes32(h.header.ParentHash)
+}
+func (h *RootBlockHeader) Time() Long { return Long(h.header.Time) }
+
+// MinorBlockHeader resolves types.MinorBlockHeader.
+type MinorBlockHeader struct {
+	header *types.MinorBlockHeader
+}
+
+func (h *MinorBlockHeader) Hash() Bytes32   { return Bytes32(h.header.Hash()) }
+func (h *MinorBlockHeader) Number() Long    { return Long(h.header.Number) }
+func (h *MinorBlockHeader) Branch() *Branch { return &Branch{value: h.header.Branch} }
+func (h *MinorBlockHeader) ParentHash() Bytes32 {
+	return Bytes32(h.header.ParentHash)
+}
+func (h *MinorBlockHeader) Time() Long { return Long(h.header.Time) }
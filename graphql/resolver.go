@@ -0,0 +1,210 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Resolver is the GraphQL root resolver; every Query/Subscription method
+// below hangs off it.
+type Resolver struct {
+	backend Backend
+}
+
+// NewResolver returns a Resolver backed by b.
+func NewResolver(b Backend) *Resolver {
+	return &Resolver{backend: b}
+}
+
+// Branch wraps account.Branch so it can be addressed from the schema as a
+// first-class type instead of a raw uint32.
+type Branch struct {
+	value account.Branch
+}
+
+func (b *Branch) Value() Long    { return Long(b.value.Value) }
+func (b *Branch) ChainId() int32 { return int32(b.value.GetChainID()) }
+func (b *Branch) ShardId() int32 { return int32(b.value.GetShardID()) }
+
+// AccountBranchData resolves rpc.AccountBranchData for a single branch.
+type AccountBranchData struct {
+	branch *Branch
+	data   *rpc.AccountBranchData
+}
+
+func (a *AccountBranchData) Branch() *Branch { return a.branch }
+func (a *AccountBranchData) TransactionCount() Long {
+	return Long(a.data.TransactionCount)
+}
+
+// Balance reports the full wei-scale balance. It returns BigInt, not Long
+// (int64): int64 still wraps silently for any balance over ~9.22 tokens at
+// 18-decimal precision, the common case rather than an edge case.
+func (a *AccountBranchData) Balance() *BigInt { return (*BigInt)(a.data.Balance) }
+func (a *AccountBranchData) IsContract() bool { return a.data.IsContract }
+
+// Account resolves an address across every branch it has data on.
+type Account struct {
+	resolver *Resolver
+	address  account.Address
+	branches map[account.Branch]*rpc.AccountBranchData
+}
+
+func (a *Account) Address() Bytes     { return Bytes(a.address.Recipient.Bytes()) }
+func (a *Account) FullShardKey() Long { return Long(a.address.FullShardKey) }
+
+func (a *Account) BranchData() []*AccountBranchData {
+	out := make([]*AccountBranchData, 0, len(a.branches))
+	for branch, data := range a.branches {
+		out = append(out, &AccountBranchData{branch: &Branch{value: branch}, data: data})
+	}
+	return out
+}
+
+func (a *Account) PrimaryBranchData(ctx context.Context) (*AccountBranchData, error) {
+	data, err := a.resolver.backend.GetPrimaryAccountData(a.address, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &AccountBranchData{branch: &Branch{value: data.Branch}, data: data}, nil
+}
+
+// Account resolves a single address. It is a thin wrapper over Accounts so
+// both entry points share the same batching path.
+func (r *Resolver) Account(ctx context.Context, args struct {
+	Address      Bytes
+	FullShardKey Long
+}) (*Account, error) {
+	accounts, err := r.Accounts(ctx, struct {
+		Addresses     []Bytes
+		FullShardKeys []Long
+	}{Addresses: []Bytes{args.Address}, FullShardKeys: []Long{args.FullShardKey}})
+	if err != nil {
+		return nil, err
+	}
+	return accounts[0], nil
+}
+
+// Accounts resolves every address in one pass, via GetAccountDataBatch: a
+// query touching N accounts on M shards issues at most M slave RPCs total
+// (one GetAccountDataBatch call per slave, covering every distinct address
+// at once), not N*M.
+func (r *Resolver) Accounts(ctx context.Context, args struct {
+	Addresses     []Bytes
+	FullShardKeys []Long
+}) ([]*Account, error) {
+	addrs := make([]account.Address, len(args.Addresses))
+	order := make([]account.Address, 0, len(args.Addresses))
+	distinct := make(map[account.Address]bool)
+	for i, raw := range args.Addresses {
+		addr := account.Address{FullShardKey: uint32(args.FullShardKeys[i])}
+		addr.Recipient.SetBytes(raw)
+		addrs[i] = addr
+		if !distinct[addr] {
+			distinct[addr] = true
+			order = append(order, addr)
+		}
+	}
+
+	branchesByAddress, err := r.backend.GetAccountDataBatch(order, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Account, 0, len(addrs))
+	for _, addr := range addrs {
+		out = append(out, &Account{resolver: r, address: addr, branches: branchesByAddress[addr]})
+	}
+	return out, nil
+}
+
+// CurrentRootBlock resolves the head of rootBlockChain.
+func (r *Resolver) CurrentRootBlock() *RootBlock {
+	return &RootBlock{block: r.backend.CurrentBlock()}
+}
+
+// ShardStats resolves cluster/shard stats, optionally filtered to a single
+// branch.
+func (r *Resolver) ShardStats(args struct{ Branch *Long }) []*ShardStatsResolver {
+	stats := r.backend.ShardStats()
+	out := make([]*ShardStatsResolver, 0, len(stats))
+	for branch, s := range stats {
+		if args.Branch != nil && Long(branch) != *args.Branch {
+			continue
+		}
+		out = append(out, &ShardStatsResolver{branch: &Branch{value: account.Branch{Value: branch}}, stats: s})
+	}
+	return out
+}
+
+// ShardStatsResolver resolves rpc.ShardStats.
+type ShardStatsResolver struct {
+	branch *Branch
+	stats  *rpc.ShardStats
+}
+
+func (s *ShardStatsResolver) Branch() *Branch { return s.branch }
+func (s *ShardStatsResolver) Height() Long    { return Long(s.stats.Height) }
+func (s *ShardStatsResolver) PendingTxCount() Long {
+	return Long(s.stats.PendingTxCount)
+}
+
+// MinorBlockResolver resolves types.MinorBlock, proxied through whichever
+// slave owns the requested branch.
+type MinorBlockResolver struct {
+	header *MinorBlockHeader
+	txs    []*TransactionResolver
+}
+
+func (m *MinorBlockResolver) Header() *MinorBlockHeader            { return m.header }
+func (m *MinorBlockResolver) Transactions() []*TransactionResolver { return m.txs }
+
+// TransactionResolver resolves types.Transaction.
+type TransactionResolver struct {
+	hash   common.Hash
+	branch *Branch
+}
+
+func (t *TransactionResolver) Hash() Bytes32   { return Bytes32(t.hash) }
+func (t *TransactionResolver) Branch() *Branch { return t.branch }
+
+// MinorBlock looks up a single minor block by branch+hash on the slave
+// that owns the branch.
+func (r *Resolver) MinorBlock(ctx context.Context, args struct {
+	Branch Long
+	Hash   Bytes32
+}) (*MinorBlockResolver, error) {
+	branch := account.Branch{Value: uint32(args.Branch)}
+	block, err := r.backend.GetMinorBlockByHash(branch, common.Hash(args.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, nil
+	}
+	txs := make([]*TransactionResolver, 0, len(block.Transactions()))
+	for _, tx := range block.Transactions() {
+		txs = append(txs, &TransactionResolver{hash: tx.Hash(), branch: &Branch{value: branch}})
+	}
+	return &MinorBlockResolver{header: &MinorBlockHeader{header: block.Header()}, txs: txs}, nil
+}
+
+// Transaction looks up a single transaction by branch+hash on the slave
+// that owns the branch.
+func (r *Resolver) Transaction(ctx context.Context, args struct {
+	Branch Long
+	Hash   Bytes32
+}) (*TransactionResolver, error) {
+	branch := account.Branch{Value: uint32(args.Branch)}
+	tx, err := r.backend.GetTransactionByHash(branch, common.Hash(args.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, nil
+	}
+	return &TransactionResolver{hash: tx.Hash(), branch: &Branch{value: branch}}, nil
+}
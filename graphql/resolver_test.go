@@ -0,0 +1,136 @@
+package graphql
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/QuarkChain/goquarkchain/account"
+	"github.com/QuarkChain/goquarkchain/cluster/master/filters"
+	"github.com/QuarkChain/goquarkchain/cluster/rpc"
+	"github.com/QuarkChain/goquarkchain/core/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type fakeBackend struct {
+	getAccountDataCalls      int
+	getAccountDataBatchCalls int
+}
+
+func (f *fakeBackend) GetAccountData(address account.Address, height *uint64) (map[account.Branch]*rpc.AccountBranchData, error) {
+	f.getAccountDataCalls++
+	return map[account.Branch]*rpc.AccountBranchData{
+		{Value: address.FullShardKey}: {Branch: account.Branch{Value: address.FullShardKey}},
+	}, nil
+}
+
+func (f *fakeBackend) GetAccountDataBatch(addresses []account.Address, height *uint64) (map[account.Address]map[account.Branch]*rpc.AccountBranchData, error) {
+	f.getAccountDataBatchCalls++
+	out := make(map[account.Address]map[account.Branch]*rpc.AccountBranchData, len(addresses))
+	for _, addr := range addresses {
+		out[addr] = map[account.Branch]*rpc.AccountBranchData{
+			{Value: addr.FullShardKey}: {Branch: account.Branch{Value: addr.FullShardKey}},
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) GetPrimaryAccountData(address account.Address, blockHeight *uint64) (*rpc.AccountBranchData, error) {
+	return &rpc.AccountBranchData{Branch: account.Branch{Value: address.FullShardKey}}, nil
+}
+
+func (f *fakeBackend) CurrentBlock() *types.RootBlock { return nil }
+
+func (f *fakeBackend) ShardStats() map[uint32]*rpc.ShardStats { return nil }
+
+func (f *fakeBackend) GetMinorBlockByHash(branch account.Branch, hash common.Hash) (*types.MinorBlock, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) GetTransactionByHash(branch account.Branch, hash common.Hash) (*types.Transaction, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) EventSystem() *filters.EventSystem { return nil }
+
+// TestAccountBranchDataBalanceAboveMaxInt64 checks that a balance bigger
+// than math.MaxInt64 (i.e. more than ~9.22 tokens at 18-decimal precision,
+// the common case rather than an edge case) round-trips exactly instead of
+// wrapping the way Long (int64) would.
+func TestAccountBranchDataBalanceAboveMaxInt64(t *testing.T) {
+	want, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if !ok {
+		t.Fatal("failed to parse test balance")
+	}
+
+	abd := &AccountBranchData{data: &rpc.AccountBranchData{Balance: want}}
+	got := (*big.Int)(abd.Balance())
+	if got.Cmp(want) != 0 {
+		t.Fatalf("Balance: expected %s, got %s", want, got)
+	}
+
+	encoded, err := abd.Balance().MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	var decoded BigInt
+	if err := (&decoded).UnmarshalGraphQL(string(encoded[1 : len(encoded)-1])); err != nil {
+		t.Fatalf("UnmarshalGraphQL: %v", err)
+	}
+	if (*big.Int)(&decoded).Cmp(want) != 0 {
+		t.Fatalf("round trip: expected %s, got %s", want, (*big.Int)(&decoded))
+	}
+}
+
+// TestAccountsDedupesRepeatedAddresses checks that resolving the same
+// address three times in one query only asks GetAccountDataBatch to
+// resolve it once.
+func TestAccountsDedupesRepeatedAddresses(t *testing.T) {
+	backend := &fakeBackend{}
+	r := NewResolver(backend)
+
+	addr := make(Bytes, 20)
+	accounts, err := r.Accounts(context.Background(), struct {
+		Addresses     []Bytes
+		FullShardKeys []Long
+	}{
+		Addresses:     []Bytes{addr, addr, addr},
+		FullShardKeys: []Long{1, 1, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.getAccountDataBatchCalls != 1 {
+		t.Fatalf("expected 1 GetAccountDataBatch call for the whole query, got %d", backend.getAccountDataBatchCalls)
+	}
+	if len(accounts) != 3 {
+		t.Fatalf("expected 3 resolved accounts (one per requested address), got %d", len(accounts))
+	}
+}
+
+// TestAccountsIssuesOneBatchCallRegardlessOfDistinctAddressCount is the
+// backlog requirement directly: N distinct accounts still cost a single
+// GetAccountDataBatch call, i.e. the slave fan-out is O(M) (shards), not
+// O(N) (addresses).
+func TestAccountsIssuesOneBatchCallRegardlessOfDistinctAddressCount(t *testing.T) {
+	backend := &fakeBackend{}
+	r := NewResolver(backend)
+
+	addrA := make(Bytes, 20)
+	addrB := make(Bytes, 20)
+	addrB[0] = 1
+
+	_, err := r.Accounts(context.Background(), struct {
+		Addresses     []Bytes
+		FullShardKeys []Long
+	}{
+		Addresses:     []Bytes{addrA, addrB},
+		FullShardKeys: []Long{1, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.getAccountDataBatchCalls != 1 {
+		t.Fatalf("expected 1 GetAccountDataBatch call for 2 distinct addresses, got %d", backend.getAccountDataBatchCalls)
+	}
+}
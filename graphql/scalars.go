@@ -0,0 +1,122 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Long is the Int64 GraphQL scalar used by every field in schema.go typed
+// Long!: graphql-go's built-in Int is 32-bit, too small for block heights
+// and tx counts, the way go-ethereum's own graphql package handles the same
+// problem. Wei-scale balances use BigInt instead.
+type Long int64
+
+// ImplementsGraphQLType satisfies graphql-go's custom-scalar contract.
+func (Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+// UnmarshalGraphQL decodes a Long argument from whichever representation
+// graphql-go hands it (query variables arrive as float64/int32/string).
+func (l *Long) UnmarshalGraphQL(input interface{}) error {
+	switch v := input.(type) {
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*l = Long(n)
+	case int32:
+		*l = Long(v)
+	case int64:
+		*l = Long(v)
+	case float64:
+		*l = Long(v)
+	default:
+		return fmt.Errorf("unexpected type %T for Long", input)
+	}
+	return nil
+}
+
+// MarshalJSON renders a Long as a plain JSON number.
+func (l Long) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(l), 10)), nil
+}
+
+// Bytes is the Bytes GraphQL scalar: arbitrary-length byte data rendered as
+// a 0x-prefixed hex string, rather than JSON's default base64 []byte
+// encoding.
+type Bytes []byte
+
+func (Bytes) ImplementsGraphQLType(name string) bool { return name == "Bytes" }
+
+func (b *Bytes) UnmarshalGraphQL(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return fmt.Errorf("unexpected type %T for Bytes", input)
+	}
+	decoded, err := hexutil.Decode(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hexutil.Encode(b))
+}
+
+// Bytes32 is the fixed-length variant of Bytes, for hashes.
+type Bytes32 [32]byte
+
+func (Bytes32) ImplementsGraphQLType(name string) bool { return name == "Bytes32" }
+
+func (b *Bytes32) UnmarshalGraphQL(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return fmt.Errorf("unexpected type %T for Bytes32", input)
+	}
+	decoded, err := hexutil.Decode(s)
+	if err != nil {
+		return err
+	}
+	if len(decoded) != len(b) {
+		return fmt.Errorf("expected 32 bytes, got %d", len(decoded))
+	}
+	copy(b[:], decoded)
+	return nil
+}
+
+func (b Bytes32) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hexutil.Encode(b[:]))
+}
+
+// BigInt is the arbitrary-precision GraphQL scalar used for wei-scale
+// values like AccountBranchData.balance: Long (int64) silently wraps past
+// ~9.22e18 wei, i.e. just above 9.22 tokens at 18-decimal precision, so
+// anything denominated in full native-token units needs this instead.
+// Rendered as a 0x-prefixed hex string, matching go-ethereum's
+// hexutil.Big.
+type BigInt big.Int
+
+func (*BigInt) ImplementsGraphQLType(name string) bool { return name == "BigInt" }
+
+func (b *BigInt) UnmarshalGraphQL(input interface{}) error {
+	s, ok := input.(string)
+	if !ok {
+		return fmt.Errorf("unexpected type %T for BigInt", input)
+	}
+	var hb hexutil.Big
+	if err := hb.UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+	*b = BigInt(hb)
+	return nil
+}
+
+func (b BigInt) MarshalJSON() ([]byte, error) {
+	return json.Marshal((*hexutil.Big)(&b).String())
+}
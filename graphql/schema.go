@@ -0,0 +1,94 @@
+package graphql
+
+// schema is the GraphQL SDL served by the Service. QuarkChain-specific
+// concepts are modeled as typed fields (a Branch type carrying chainId and
+// shardId, fullShardKey as a Long) rather
+// than being flattened into opaque hex strings the way a naive port of
+// go-ethereum's schema would.
+const schema = `
+  schema {
+    query: Query
+    subscription: Subscription
+  }
+
+  # Branch identifies a shard: chainId/shardId packed the same way
+  # account.Branch packs them, plus the decoded components for convenience.
+  type Branch {
+    value: Long!
+    chainId: Int!
+    shardId: Int!
+  }
+
+  type AccountBranchData {
+    branch: Branch!
+    transactionCount: Long!
+    balance: BigInt!
+    isContract: Boolean!
+  }
+
+  type Account {
+    address: Bytes!
+    fullShardKey: Long!
+    # balances/txCount across every branch the address touches
+    branchData: [AccountBranchData!]!
+    # the single branch address.FullShardKey maps to right now
+    primaryBranchData: AccountBranchData
+  }
+
+  type MinorBlockHeader {
+    hash: Bytes32!
+    number: Long!
+    branch: Branch!
+    parentHash: Bytes32!
+    time: Long!
+  }
+
+  type RootBlockHeader {
+    hash: Bytes32!
+    number: Long!
+    parentHash: Bytes32!
+    time: Long!
+  }
+
+  type RootBlock {
+    header: RootBlockHeader!
+    minorHeaders: [MinorBlockHeader!]!
+  }
+
+  type ShardStats {
+    branch: Branch!
+    height: Long!
+    pendingTxCount: Long!
+  }
+
+  type Transaction {
+    hash: Bytes32!
+    branch: Branch!
+  }
+
+  type MinorBlock {
+    header: MinorBlockHeader!
+    transactions: [Transaction!]!
+  }
+
+  type Query {
+    account(address: Bytes!, fullShardKey: Long!): Account!
+    # accounts batches its resolution: N addresses spread across M branches
+    # still costs at most M slave round trips, not N.
+    accounts(addresses: [Bytes!]!, fullShardKeys: [Long!]!): [Account!]!
+    currentRootBlock: RootBlock!
+    shardStats(branch: Long): [ShardStats!]!
+    minorBlock(branch: Long!, hash: Bytes32!): MinorBlock
+    transaction(branch: Long!, hash: Bytes32!): Transaction
+  }
+
+  type Subscription {
+    newRootHead: RootBlock!
+    shardStatsUpdates(branch: Long): ShardStats!
+  }
+
+  scalar Bytes
+  scalar Bytes32
+  scalar BigInt
+  scalar Long
+`
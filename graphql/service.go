@@ -0,0 +1,31 @@
+package graphql
+
+import (
+	"net/http"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+// Service is the http.Handler exposing the GraphQL schema over HTTP,
+// mirroring how go-ethereum's graphql.Service wraps graph-gophers.
+type Service struct {
+	handler http.Handler
+}
+
+// New parses the schema against a Resolver bound to backend and returns
+// the Service. It is an http.Handler, not a JSON-RPC service: callers mount
+// it over HTTP (see QKCMasterBackend.GraphQLHandler), gated behind the
+// --graphql CLI flag.
+func New(backend Backend) (*Service, error) {
+	parsedSchema, err := graphqlgo.ParseSchema(schema, NewResolver(backend))
+	if err != nil {
+		return nil, err
+	}
+	return &Service{handler: &relay.Handler{Schema: parsedSchema}}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
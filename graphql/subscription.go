@@ -0,0 +1,49 @@
+package graphql
+
+import (
+	"context"
+
+	"github.com/QuarkChain/goquarkchain/core/types"
+)
+
+// NewRootHead streams every new root block, the GraphQL equivalent of the
+// eth_subscribe("newRootHeads") subscription proposed alongside this
+// package.
+func (r *Resolver) NewRootHead(ctx context.Context) <-chan *RootBlock {
+	out := make(chan *RootBlock)
+	blocks := make(chan *types.RootBlock)
+	sub := r.backend.EventSystem().SubscribeNewRootHeads(blocks)
+
+	go func() {
+		defer sub.Unsubscribe()
+		defer close(out)
+		for {
+			select {
+			case b := <-blocks:
+				select {
+				case out <- &RootBlock{block: b}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-sub.Err():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// ShardStatsUpdates would stream shard stats updates, optionally filtered
+// to a single branch, driven off MinorHeadEvent. Nothing posts
+// MinorHeadEvent yet: it requires the slave event streaming RPC described
+// on master.SlaveConn.SubscribeEvents, which does not exist in this tree.
+// Rather than install a subscription that looks live but can never deliver
+// anything, this closes the output channel immediately so a client sees
+// the subscription end right away instead of hanging forever.
+func (r *Resolver) ShardStatsUpdates(ctx context.Context, args struct{ Branch *Long }) <-chan *ShardStatsResolver {
+	out := make(chan *ShardStatsResolver)
+	close(out)
+	return out
+}
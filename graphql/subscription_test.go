@@ -0,0 +1,18 @@
+package graphql
+
+import (
+	"context"
+	"testing"
+)
+
+// TestShardStatsUpdatesClosesImmediately checks that ShardStatsUpdates ends
+// the subscription right away instead of hanging forever: nothing in this
+// tree posts the MinorHeadEvent it would need to ever deliver an update.
+func TestShardStatsUpdatesClosesImmediately(t *testing.T) {
+	r := NewResolver(&fakeBackend{})
+
+	ch := r.ShardStatsUpdates(context.Background(), struct{ Branch *Long }{})
+	if _, ok := <-ch; ok {
+		t.Fatal("expected ShardStatsUpdates to close its channel immediately")
+	}
+}